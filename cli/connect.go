@@ -1,32 +1,48 @@
 package cli
 
 import (
+	"flag"
 	"fmt"
 
 	"github.com/apigear-io/objectlink-core-go/olink/client"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
 	"github.com/apigear-io/objectlink-core-go/olink/ws"
 )
 
 var cmdConnect = Command{
-	Usage: "connect <url>",
+	Usage: "connect [--ca file] [--insecure] <url>",
 	Names: []string{"c", "con", "connect"},
 	Exec: func(args []string) error {
 		url := "ws://localhost:5555/ws"
 		if registry == nil {
 			return fmt.Errorf("no registry")
 		}
-		if len(args) == 2 {
-			url = args[1]
+		fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+		caFile := fs.String("ca", "", "PEM bundle of trusted certificate authorities")
+		insecure := fs.Bool("insecure", false, "skip verification of the server certificate")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if fs.NArg() == 1 {
+			url = fs.Arg(0)
+		}
+		opts := ws.DefaultOptions()
+		opts.TLSConfig, err = dialTLSConfig(url, *caFile, *insecure)
+		if err != nil {
+			return err
 		}
-		c, err := ws.Dial(ctx, url)
+		c, err := ws.DialWithOptions(ctx, url, opts)
 		if err != nil {
 			return err
 		}
 		conn = c
 		node = client.NewNode(registry)
+		node.SetMessageFormat(conn.MessageFormat())
 		node.SetOutput(conn)
 		conn.SetOutput(node)
-		fmt.Printf("connection %s connected to %s using node %s\n", conn.Id(), url, node.Id())
+		node.RequestFeatures(core.FeatureBatchedChanges, core.FeatureRemoteUnlink)
+		fmt.Printf("connection %s connected to %s using node %s (%s)\n", conn.Id(), url, node.Id(), conn.MessageFormat())
 		return nil
 	},
 	Help: "connect to server",
@@ -2,12 +2,14 @@ package cli
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/apigear-io/objectlink-core-go/log"
 	"github.com/apigear-io/objectlink-core-go/olink/core"
@@ -22,6 +24,7 @@ type GenericSource struct {
 }
 
 var _ remote.IObjectSource = (*GenericSource)(nil)
+var _ remote.IRestorableSource = (*GenericSource)(nil)
 
 func NewGenericSource(objectId string) *GenericSource {
 	log.Info().Str("objectId", objectId).Msg("create new source")
@@ -82,23 +85,72 @@ func (s *GenericSource) Linked(objectId string, node *remote.Node) error {
 func (s *GenericSource) CollectProperties() (core.KWArgs, error) {
 	return s.properties, nil
 }
+func (s *GenericSource) RestoreProperties(props core.KWArgs) error {
+	log.Info().Str("objectId", s.objectId).Msgf("restore properties %v", props)
+	for k, v := range props {
+		s.properties[k] = v
+	}
+	return nil
+}
 
 func GenericSourceFactory(objectId string) remote.IObjectSource {
 	return NewGenericSource(objectId)
 }
 
+// HubOptions configures the server started by RunHubWithOptions.
+type HubOptions struct {
+	// StorePath is the file the properties of all objects are persisted to,
+	// empty disables persistence.
+	StorePath string
+	// TLS enables wss connections.
+	TLS TLSOptions
+}
+
+// RunHub runs an objectlink server on addr.
 func RunHub(addr string) {
+	RunHubWithOptions(addr, HubOptions{})
+}
+
+// RunHubWithOptions runs an objectlink server on addr using the options.
+func RunHubWithOptions(addr string, opts HubOptions) {
 	registry := remote.NewRegistry()
 	registry.SetSourceFactory(GenericSourceFactory)
+	if opts.StorePath != "" {
+		store, err := remote.NewFileStore(opts.StorePath)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to open property store")
+			return
+		}
+		err = registry.SetPropertyStore(store, remote.PersistOptions{Interval: time.Second})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to set property store")
+		}
+		log.Info().Msgf("persisting properties to %s", opts.StorePath)
+	}
 	hub := ws.NewHub(ctx, registry)
 	server := &http.Server{
 		Addr: addr,
 	}
+	scheme := "ws"
+	if opts.TLS.Enabled() {
+		cfg, err := opts.TLS.serverConfig(addr)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to configure tls")
+			return
+		}
+		server.TLSConfig = cfg
+		scheme = "wss"
+	}
 	http.Handle("/ws", hub)
 
 	go func() {
-		log.Info().Msgf("objectlink server listening on ws://%s/ws", addr)
-		err := server.ListenAndServe()
+		log.Info().Msgf("objectlink server listening on %s://%s/ws", scheme, addr)
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
 		if err != nil && err != http.ErrServerClosed {
 			log.Error().Err(err).Msg("failed to start web socket server")
 		}
@@ -107,23 +159,48 @@ func RunHub(addr string) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
-	ctx := context.Background()
-	err := server.Shutdown(ctx)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := hub.Shutdown(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to drain web socket connections")
+	}
+	log.Info().Msgf("closed %d connections, %d forced, %d invokes abandoned, %d messages dropped",
+		report.Connections, report.ForcedClose, report.AbandonedInvokes, report.DroppedMessages)
+	err = server.Shutdown(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to shutdown web socket server")
 	}
+	err = registry.SetPropertyStore(nil, remote.PersistOptions{})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to save properties")
+	}
 	log.Info().Msg("web socket server shutdown")
 }
 
 var cmdServe = Command{
-	Usage: "serve <addr>",
+	Usage: "serve [--cert file --key file] [--self-signed] <addr> [store.json|store.yaml]",
 	Names: []string{"s", "serve"},
 	Exec: func(args []string) error {
+		var tlsOpts TLSOptions
+		fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+		fs.StringVar(&tlsOpts.CertFile, "cert", "", "PEM certificate file")
+		fs.StringVar(&tlsOpts.KeyFile, "key", "", "PEM private key file")
+		fs.BoolVar(&tlsOpts.SelfSigned, "self-signed", false, "generate a self-signed certificate, written to new --cert/--key files if given")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		args = fs.Args()
 		addr := "localhost:5555"
+		if len(args) > 0 {
+			addr = args[0]
+		}
+		storePath := ""
 		if len(args) > 1 {
-			addr = args[1]
+			storePath = args[1]
 		}
-		RunHub(addr)
+		RunHubWithOptions(addr, HubOptions{StorePath: storePath, TLS: tlsOpts})
 		return nil
 	},
 	Help: "start an objectlink server",
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/log"
+)
+
+// TLSOptions configures the TLS certificate of the server.
+type TLSOptions struct {
+	// CertFile and KeyFile are the PEM encoded certificate and private key.
+	CertFile string
+	KeyFile  string
+	// SelfSigned generates a self-signed certificate for local testing.
+	// If CertFile and KeyFile are set, the generated pair is written to them
+	// so clients can trust the certificate. Existing files are never overwritten.
+	SelfSigned bool
+}
+
+// Enabled returns true if the server uses TLS.
+func (o TLSOptions) Enabled() bool {
+	return o.SelfSigned || o.CertFile != "" || o.KeyFile != ""
+}
+
+// serverConfig returns the TLS configuration of a server listening on addr
+func (o TLSOptions) serverConfig(addr string) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case o.SelfSigned:
+		cert, err = selfSignedCertificate(addr)
+		if err != nil {
+			return nil, err
+		}
+		if o.CertFile != "" && o.KeyFile != "" {
+			err = writeCertificate(cert, o.CertFile, o.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		log.Info().Msgf("self-signed certificate sha256 fingerprint %x", sha256.Sum256(cert.Certificate[0]))
+	case o.CertFile == "" || o.KeyFile == "":
+		return nil, fmt.Errorf("tls requires both certificate and key file")
+	default:
+		cert, err = tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load certificate: %w", err)
+		}
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// selfSignedCertificate generates a certificate for localhost and the host of addr
+func selfSignedCertificate(addr string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "olink", Organization: []string{"objectlink self-signed"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" && host != "localhost" {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// writeCertificate writes the certificate and private key as new PEM files,
+// it fails if one of the files already exists
+func writeCertificate(cert tls.Certificate, certFile, keyFile string) error {
+	for _, name := range []string{certFile, keyFile} {
+		if _, err := os.Stat(name); err == nil {
+			return fmt.Errorf("%s already exists, remove it or drop --cert/--key to use a self-signed certificate", name)
+		}
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	err = writeNewFile(certFile, certPEM, 0644)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	return writeNewFile(keyFile, keyPEM, 0600)
+}
+
+// writeNewFile writes the data to a file which must not exist
+func writeNewFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// dialTLSConfig returns the TLS configuration to dial the url, nil for ws urls.
+// The ca file and insecure flags are only valid for wss urls.
+func dialTLSConfig(url string, caFile string, insecure bool) (*tls.Config, error) {
+	if !strings.HasPrefix(url, "wss://") {
+		if caFile != "" || insecure {
+			return nil, fmt.Errorf("--ca and --insecure require a wss url, got %s", url)
+		}
+		return nil, nil
+	}
+	return clientTLSConfig(caFile, insecure)
+}
+
+// clientTLSConfig returns the TLS configuration to dial wss urls.
+// The certificates in caFile are trusted in addition to the system roots.
+func clientTLSConfig(caFile string, insecure bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSOptionsEnabled(t *testing.T) {
+	assert.False(t, TLSOptions{}.Enabled())
+	assert.True(t, TLSOptions{SelfSigned: true}.Enabled())
+	assert.True(t, TLSOptions{CertFile: "cert.pem"}.Enabled())
+	_, err := TLSOptions{CertFile: "cert.pem"}.serverConfig("localhost:5555")
+	assert.ErrorContains(t, err, "both certificate and key")
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, err := selfSignedCertificate("192.168.1.2:5555")
+	assert.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	assert.Nil(t, leaf.VerifyHostname("localhost"))
+	assert.Nil(t, leaf.VerifyHostname("127.0.0.1"))
+	assert.Nil(t, leaf.VerifyHostname("192.168.1.2"))
+	cert, err = selfSignedCertificate("example.com:5555")
+	assert.Nil(t, err)
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	assert.Nil(t, leaf.VerifyHostname("example.com"))
+}
+
+func TestSelfSignedWritesNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	opts := TLSOptions{
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		SelfSigned: true,
+	}
+	cfg, err := opts.serverConfig("localhost:5555")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(cfg.Certificates))
+	// the written pair loads as the served certificate
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, cfg.Certificates[0].Certificate[0], cert.Certificate[0])
+	info, err := os.Stat(opts.KeyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	// the existing files are loaded without the self-signed option
+	opts.SelfSigned = false
+	cfg, err = opts.serverConfig("localhost:5555")
+	assert.Nil(t, err)
+	assert.Equal(t, cert.Certificate[0], cfg.Certificates[0].Certificate[0])
+}
+
+func TestSelfSignedNeverOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(keyFile, []byte("existing key"), 0600))
+	opts := TLSOptions{CertFile: certFile, KeyFile: keyFile, SelfSigned: true}
+	_, err := opts.serverConfig("localhost:5555")
+	assert.ErrorContains(t, err, "already exists")
+	data, err := os.ReadFile(keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "existing key", string(data))
+	_, err = os.Stat(certFile)
+	assert.True(t, os.IsNotExist(err))
+	// a file created concurrently is not overwritten either
+	assert.Nil(t, os.WriteFile(certFile, []byte("existing cert"), 0644))
+	assert.Error(t, writeNewFile(certFile, []byte("new"), 0644))
+	data, err = os.ReadFile(certFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "existing cert", string(data))
+}
+
+func TestDialTLSConfig(t *testing.T) {
+	cfg, err := dialTLSConfig("ws://localhost:5555/ws", "", false)
+	assert.Nil(t, err)
+	assert.Nil(t, cfg)
+	_, err = dialTLSConfig("ws://localhost:5555/ws", "ca.pem", false)
+	assert.ErrorContains(t, err, "require a wss url")
+	_, err = dialTLSConfig("ws://localhost:5555/ws", "", true)
+	assert.ErrorContains(t, err, "require a wss url")
+	cfg, err = dialTLSConfig("wss://localhost:5555/ws", "", true)
+	assert.Nil(t, err)
+	assert.True(t, cfg.InsecureSkipVerify)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+}
+
+func TestClientTLSConfigCA(t *testing.T) {
+	dir := t.TempDir()
+	cert, err := selfSignedCertificate("localhost:5555")
+	assert.Nil(t, err)
+	caFile := filepath.Join(dir, "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	assert.Nil(t, os.WriteFile(caFile, data, 0644))
+	cfg, err := clientTLSConfig(caFile, false)
+	assert.Nil(t, err)
+	assert.False(t, cfg.InsecureSkipVerify)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: cfg.RootCAs, DNSName: "localhost"})
+	assert.Nil(t, err)
+	// missing files and files without certificates are rejected
+	_, err = clientTLSConfig(filepath.Join(dir, "missing.pem"), false)
+	assert.ErrorContains(t, err, "read ca bundle")
+	empty := filepath.Join(dir, "empty.pem")
+	assert.Nil(t, os.WriteFile(empty, []byte("no pem"), 0644))
+	_, err = clientTLSConfig(empty, false)
+	assert.ErrorContains(t, err, "no certificates")
+}
@@ -123,6 +123,7 @@ func main() {
 	}
 	defer conn.Close()
 	node := client.NewNode(registry)
+	node.SetMessageFormat(conn.MessageFormat())
 	node.SetOutput(conn)
 	conn.SetOutput(node)
 	registry.AttachClientNode(node)
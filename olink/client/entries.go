@@ -106,6 +106,18 @@ func (e *clientEntries) getNode(objectId string) *Node {
 	return entry.getNode()
 }
 
+// setState sets the link state for the object id.
+func (e *clientEntries) setState(objectId string, state LinkState) {
+	entry := e.getEntry(objectId)
+	entry.setState(state)
+}
+
+// getState returns the link state for the object id.
+func (e *clientEntries) getState(objectId string) LinkState {
+	entry := e.getEntry(objectId)
+	return entry.getState()
+}
+
 // clearNode clears the node for the object id.
 func (e *clientEntries) clearNode(objectId string) {
 	entry := e.getEntry(objectId)
@@ -6,10 +6,39 @@ import (
 	"github.com/apigear-io/objectlink-core-go/log"
 )
 
+// LinkState is the state of the link between a sink and the remote object.
+type LinkState int
+
+const (
+	// LinkStateUnlinked means the sink is not linked.
+	LinkStateUnlinked LinkState = iota
+	// LinkStateLinking means the link was requested and no init was received yet.
+	LinkStateLinking
+	// LinkStateLinked means the sink received the init message.
+	LinkStateLinked
+	// LinkStateReleased means the remote side removed the object.
+	LinkStateReleased
+)
+
+func (s LinkState) String() string {
+	switch s {
+	case LinkStateUnlinked:
+		return "unlinked"
+	case LinkStateLinking:
+		return "linking"
+	case LinkStateLinked:
+		return "linked"
+	case LinkStateReleased:
+		return "released"
+	}
+	return "unknown"
+}
+
 type SinkToClientEntry struct {
 	sync.RWMutex
-	sink IObjectSink
-	node *Node
+	sink  IObjectSink
+	node  *Node
+	state LinkState
 }
 
 // setNode sets the node.
@@ -31,6 +60,21 @@ func (e *SinkToClientEntry) clearNode() {
 	e.Lock()
 	defer e.Unlock()
 	e.node = nil
+	e.state = LinkStateUnlinked
+}
+
+// setState sets the link state.
+func (e *SinkToClientEntry) setState(state LinkState) {
+	e.Lock()
+	defer e.Unlock()
+	e.state = state
+}
+
+// getState returns the link state.
+func (e *SinkToClientEntry) getState() LinkState {
+	e.RLock()
+	defer e.RUnlock()
+	return e.state
 }
 
 // setSink sets the sink.
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -14,9 +15,14 @@ import (
 
 var nextNodeId = helper.MakeIdGenerator("n")
 
+// ErrNodeClosed is the error of invokes which are pending when the node closes.
+var ErrNodeClosed = errors.New("node closed")
+
 type InvokeReplyArg struct {
 	Identifier string
 	Value      core.Any
+	// Error is set if the remote side replied with an error message
+	Error error
 }
 
 type InvokeReplyFunc func(arg InvokeReplyArg)
@@ -29,6 +35,10 @@ type Node struct {
 	seqId    atomic.Int64
 	conv     core.MessageConverter
 	output   io.WriteCloser
+	// features accepted by the remote side
+	features map[string]bool
+	// closed is set when the node is closed, invokes fail immediately
+	closed bool
 }
 
 func NewNode(registry *Registry) *Node {
@@ -36,6 +46,7 @@ func NewNode(registry *Registry) *Node {
 		id:       nextNodeId(),
 		registry: registry,
 		pending:  make(map[int64]InvokeReplyFunc),
+		features: make(map[string]bool),
 		conv: core.MessageConverter{
 			Format: core.FormatJson,
 		},
@@ -50,9 +61,21 @@ func (n *Node) Registry() *Registry {
 	return n.registry
 }
 
+// Close detaches the node from the registry
+// and fails all pending invokes with ErrNodeClosed.
 func (n *Node) Close() error {
 	log.Debug().Msgf("node %s: closing", n.Id())
 	n.registry.DetachClientNode(n)
+	n.mu.Lock()
+	n.closed = true
+	pending := n.pending
+	n.pending = make(map[int64]InvokeReplyFunc)
+	n.mu.Unlock()
+	for _, fn := range pending {
+		if fn != nil {
+			fn(InvokeReplyArg{Error: ErrNodeClosed})
+		}
+	}
 	return nil
 }
 
@@ -67,7 +90,8 @@ func (n *Node) SendMessage(msg core.Message) {
 		log.Warn().Msgf("node %s: no output", n.Id())
 		return
 	}
-	data, err := n.conv.ToData(msg)
+	conv := n.converter()
+	data, err := conv.ToData(msg)
 	if err != nil {
 		log.Warn().Msgf("node %s: error converting message to data: %v", n.Id(), err)
 		return
@@ -76,17 +100,48 @@ func (n *Node) SendMessage(msg core.Message) {
 		log.Warn().Msgf("node %s: no output", n.Id())
 		return
 	}
-	_, err = n.output.Write(data)
+	_, err = core.WriteFrame(n.output, conv.Format.FrameType(), data)
 	if err != nil {
 		log.Warn().Msgf("node %s: error writing message: %v", n.Id(), err)
 		return
 	}
 }
 
+// SetMessageFormat sets the format used to encode and decode messages.
+func (n *Node) SetMessageFormat(format core.MessageFormat) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.conv = core.MessageConverter{Format: format}
+}
+
+// MessageFormat returns the format used to encode and decode messages.
+func (n *Node) MessageFormat() core.MessageFormat {
+	return n.converter().Format
+}
+
+// converter returns the message converter of the node
+func (n *Node) converter() core.MessageConverter {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.conv
+}
+
+// WriteFrame handles a message received in a transport frame.
+// Text and binary frames are both accepted, the data is always
+// decoded using the message format of the node.
+func (n *Node) WriteFrame(frame core.FrameType, data []byte) (int, error) {
+	format := n.MessageFormat()
+	if frame != format.FrameType() {
+		log.Debug().Msgf("node %s: %s frame for %s messages", n.Id(), frame, format)
+	}
+	return n.Write(data)
+}
+
 // Write handles a message from the source.
-// We handle init, property change, invoke reply, signal messages.
+// We handle init, unlink, property change, invoke reply, signal messages.
 func (n *Node) Write(data []byte) (int, error) {
-	msg, err := n.conv.FromData(data)
+	conv := n.converter()
+	msg, err := conv.FromData(data)
 	log.Debug().Msgf("%s <- %v", n.Id(), msg)
 	if err != nil {
 		return 0, err
@@ -99,8 +154,16 @@ func (n *Node) Write(data []byte) (int, error) {
 		if sink == nil {
 			return 0, fmt.Errorf("no sink for %s", objectId)
 		}
+		n.registry.entries.setState(objectId, LinkStateLinked)
 		sink.HandleInit(objectId, props, n)
 		return 0, nil
+	case core.MsgUnlink:
+		// the remote side removed the object
+		objectId := msg.AsUnlink()
+		if n.registry.GetClientNode(objectId) != n {
+			return 0, fmt.Errorf("object %s is not linked to %s", objectId, n.Id())
+		}
+		n.registry.releaseClientNode(objectId)
 	case core.MsgPropertyChange:
 		// get the sink and call the on property change method
 		propertyId, value := msg.AsPropertyChange()
@@ -110,6 +173,28 @@ func (n *Node) Write(data []byte) (int, error) {
 			return 0, fmt.Errorf("no sink for %s", propertyId)
 		}
 		sink.HandlePropertyChange(propertyId, value)
+	case core.MsgPropertyChanges:
+		// get the sink and apply all changes together if supported
+		objectId, props := msg.AsPropertyChanges()
+		sink := n.registry.ObjectSink(objectId)
+		if sink == nil {
+			return 0, fmt.Errorf("no sink for %s", objectId)
+		}
+		if bs, ok := sink.(IBatchSink); ok {
+			bs.HandlePropertyChanges(objectId, props)
+			break
+		}
+		for name, value := range props {
+			sink.HandlePropertyChange(core.MakeSymbolId(objectId, name), value)
+		}
+	case core.MsgFeatures:
+		// the remote side replied with the accepted features
+		features := msg.AsFeatures()
+		n.mu.Lock()
+		for _, f := range features {
+			n.features[f] = true
+		}
+		n.mu.Unlock()
 	case core.MsgInvokeReply:
 		// lookup the pending invoke and call the function
 		requestId, methodId, value := msg.AsInvokeReply()
@@ -126,7 +211,7 @@ func (n *Node) Write(data []byte) (int, error) {
 		n.mu.Lock()
 		delete(n.pending, requestId)
 		n.mu.Unlock()
-		fn(InvokeReplyArg{methodId, value})
+		fn(InvokeReplyArg{Identifier: methodId, Value: value})
 	case core.MsgSignal:
 		// get the sink and call the on signal method
 		signalId, args := msg.AsSignal()
@@ -140,20 +225,70 @@ func (n *Node) Write(data []byte) (int, error) {
 		// report the error
 		msgType, id, err := msg.AsError()
 		log.Info().Msgf("msg error: msgType=%d id-%d err=%s", msgType, id, err)
+		if msgType == core.MsgInvoke {
+			n.mu.Lock()
+			fn, ok := n.pending[id]
+			delete(n.pending, id)
+			n.mu.Unlock()
+			if ok && fn != nil {
+				fn(InvokeReplyArg{Error: fmt.Errorf("remote error: %s", err)})
+			}
+		}
 	default:
 		return 0, fmt.Errorf("unknown type in client message: %#v", msg)
 	}
 	return len(data), nil
 }
 
+// RequestFeatures asks the remote side to enable protocol features.
+// The remote side replies with the accepted features, see HasFeature.
+func (n *Node) RequestFeatures(features ...string) {
+	n.SendMessage(core.MakeFeaturesMessage(features))
+}
+
+// HasFeature returns true if the remote side accepted the protocol feature.
+func (n *Node) HasFeature(feature string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.features[feature]
+}
+
 func (n *Node) InvokeRemote(methodId string, args core.Args, f InvokeReplyFunc) {
+	n.invokeRemote(methodId, args, f)
+}
+
+// invokeRemote sends the invoke and returns its request id.
+// If the node is closed, f is called with ErrNodeClosed.
+func (n *Node) invokeRemote(methodId string, args core.Args, f InvokeReplyFunc) int64 {
 	seqId := n.seqId.Add(1)
 	n.mu.Lock()
-	if f != nil {
+	closed := n.closed
+	if f != nil && !closed {
 		n.pending[seqId] = f
 	}
 	n.mu.Unlock()
+	if closed {
+		if f != nil {
+			f(InvokeReplyArg{Identifier: methodId, Error: ErrNodeClosed})
+		}
+		return seqId
+	}
 	n.SendMessage(core.MakeInvokeMessage(seqId, methodId, args))
+	return seqId
+}
+
+// cancelInvoke removes a pending invoke, its reply is ignored
+func (n *Node) cancelInvoke(requestId int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.pending, requestId)
+}
+
+// pendingCount returns the number of invokes waiting for a reply
+func (n *Node) pendingCount() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return len(n.pending)
 }
 
 func (n *Node) InvokeRemoteSync(methodId string, args core.Args) (core.Any, error) {
@@ -162,7 +297,7 @@ func (n *Node) InvokeRemoteSync(methodId string, args core.Args) (core.Any, erro
 		ch <- arg
 	})
 	arg := <-ch
-	return arg.Value, nil
+	return arg.Value, arg.Error
 }
 
 func (n *Node) SetRemoteProperty(propertyId string, value core.Any) {
@@ -153,3 +153,69 @@ func TestHandleMsgInvokeReply(t *testing.T) {
 	node.Write(data)
 	assert.True(t, isCalled, "should be called")
 }
+
+func TestHandlePropertyChanges(t *testing.T) {
+	node, sink, writer := makeNodeAndSink(t)
+	node.Registry().AddObjectSink(sink)
+	node.Registry().LinkClientNode(sink.ObjectId(), node)
+	node.RequestFeatures(core.FeatureBatchedChanges)
+	assert.Equal(t, core.MsgFeatures, writer.Messages[0].Type())
+	assert.False(t, node.HasFeature(core.FeatureBatchedChanges))
+	data, err := json.Marshal(core.MakeFeaturesMessage([]string{core.FeatureBatchedChanges}))
+	assert.Nil(t, err)
+	node.Write(data)
+	assert.True(t, node.HasFeature(core.FeatureBatchedChanges))
+	// a sink without batch support receives one change per property
+	msg := core.MakePropertyChangesMessage(sink.ObjectId(), core.KWArgs{"a": 1, "b": 2})
+	data, err = json.Marshal(msg)
+	assert.Nil(t, err)
+	node.Write(data)
+	assert.Equal(t, 2, len(sink.events), "should have 2 events")
+}
+
+func TestRemoteUnlinkReleasesSink(t *testing.T) {
+	node, _, _ := makeNodeAndSink(t)
+	counter := &reflectCounter{}
+	sink, err := NewReflectSink("demo.Counter", counter)
+	assert.Nil(t, err)
+	node.Registry().AddObjectSink(sink)
+	assert.Equal(t, LinkStateUnlinked, node.Registry().LinkState(sink.ObjectId()))
+	node.LinkRemoteNode(sink.ObjectId())
+	assert.Equal(t, LinkStateLinking, node.Registry().LinkState(sink.ObjectId()))
+	data, err := json.Marshal(core.MakeInitMessage(sink.ObjectId(), core.KWArgs{"count": 1}))
+	assert.Nil(t, err)
+	node.Write(data)
+	assert.Equal(t, LinkStateLinked, node.Registry().LinkState(sink.ObjectId()))
+	assert.Equal(t, node, sink.Node())
+	data, err = json.Marshal(core.MakeUnlinkMessage(sink.ObjectId()))
+	assert.Nil(t, err)
+	_, err = node.Write(data)
+	assert.Nil(t, err)
+	assert.Equal(t, LinkStateReleased, node.Registry().LinkState(sink.ObjectId()))
+	assert.Nil(t, sink.Node())
+	assert.Nil(t, node.Registry().GetClientNode(sink.ObjectId()))
+	// a replaced object is linked again by the init message
+	data, err = json.Marshal(core.MakeInitMessage(sink.ObjectId(), core.KWArgs{"count": 2}))
+	assert.Nil(t, err)
+	node.Write(data)
+	assert.Equal(t, LinkStateLinked, node.Registry().LinkState(sink.ObjectId()))
+}
+
+func TestNodeWriteFrame(t *testing.T) {
+	node, sink, _ := makeNodeAndSink(t)
+	node.Registry().AddObjectSink(sink)
+	node.Registry().LinkClientNode(sink.ObjectId(), node)
+	node.SetMessageFormat(core.FormatMsgPack)
+	conv := core.NewConverter(core.FormatMsgPack)
+	propertyId := core.MakeSymbolId(sink.ObjectId(), "prop")
+	data, err := conv.ToData(core.MakePropertyChangeMessage(propertyId, "value"))
+	assert.Nil(t, err)
+	_, err = node.WriteFrame(core.FrameBinary, data)
+	assert.Nil(t, err)
+	// a text frame is decoded using the message format of the node as well
+	_, err = node.WriteFrame(core.FrameText, data)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sink.events), "should have 2 events")
+	_, err = node.WriteFrame(core.FrameText, []byte(`[21,"demo.Counter/prop","value"]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/log"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ReflectSink binds a remote object to a go struct.
+//
+// Struct fields tagged with `olink:"name"` are filled from the init message
+// and property changes. Signals are dispatched to func fields tagged with
+// `olink:"name,signal"` and to methods named On<Signal> (e.g. OnReset for "reset").
+// Func fields tagged with `olink:"name,method"` are set to typed invoke helpers,
+// which call the remote method and wait for the reply, e.g.
+//
+//	Increment func(step int64) (int64, error) `olink:"increment,method"`
+//
+// Fields are written while holding the sink lock, use Read to access them safely.
+type ReflectSink struct {
+	mu         sync.RWMutex
+	objectId   string
+	target     reflect.Value
+	node       *Node
+	properties map[string][]int
+	signals    map[string][]int
+	handlers   map[string]reflect.Value
+	// InvokeTimeout limits the time invoke helpers wait for a reply,
+	// zero waits until the reply arrives or the node is closed.
+	InvokeTimeout time.Duration
+}
+
+var _ IObjectSink = (*ReflectSink)(nil)
+var _ IBatchSink = (*ReflectSink)(nil)
+
+// NewReflectSink creates a sink filling the target, which must be a pointer to a struct.
+func NewReflectSink(objectId string, target any) (*ReflectSink, error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("reflect sink %s: target must be a pointer to a struct, got %T", objectId, target)
+	}
+	s := &ReflectSink{
+		objectId:   objectId,
+		target:     v,
+		properties: make(map[string][]int),
+		signals:    make(map[string][]int),
+		handlers:   make(map[string]reflect.Value),
+	}
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.IsExported() && strings.HasPrefix(m.Name, "On") && len(m.Name) > 2 {
+			s.handlers[core.MemberName(m.Name[2:])] = v.Method(i)
+		}
+	}
+	for _, f := range reflect.VisibleFields(t.Elem()) {
+		tag, ok := f.Tag.Lookup(core.TagName)
+		if !ok || !f.IsExported() {
+			continue
+		}
+		name, opts := core.ParseTag(tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = core.MemberName(f.Name)
+		}
+		switch {
+		case core.HasTagOption(opts, "signal"):
+			if f.Type.Kind() != reflect.Func {
+				return nil, fmt.Errorf("reflect sink %s: signal field %s must be a func", objectId, f.Name)
+			}
+			s.signals[name] = f.Index
+		case core.HasTagOption(opts, "method"):
+			fn, err := s.makeInvoker(name, f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("reflect sink %s: method field %s: %w", objectId, f.Name, err)
+			}
+			v.Elem().FieldByIndex(f.Index).Set(fn)
+		default:
+			s.properties[name] = f.Index
+		}
+	}
+	return s, nil
+}
+
+// ObjectId returns the object id of the sink.
+func (s *ReflectSink) ObjectId() string {
+	return s.objectId
+}
+
+// Node returns the linked client node or nil.
+func (s *ReflectSink) Node() *Node {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.node
+}
+
+// Read calls fn while holding the read lock of the sink.
+func (s *ReflectSink) Read(fn func()) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn()
+}
+
+// HandleInit fills the tagged fields from the initial properties.
+func (s *ReflectSink) HandleInit(objectId string, props core.KWArgs, node *Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.node = node
+	for name, value := range props {
+		s.setField(name, value)
+	}
+}
+
+// HandlePropertyChange sets the tagged field of the property.
+func (s *ReflectSink) HandlePropertyChange(propertyId string, value core.Any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setField(core.SymbolIdToMember(propertyId), value)
+}
+
+// HandlePropertyChanges sets the tagged fields of all properties together.
+func (s *ReflectSink) HandlePropertyChanges(objectId string, props core.KWArgs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range props {
+		s.setField(name, value)
+	}
+}
+
+// HandleSignal calls the signal func field or the On<Signal> method.
+func (s *ReflectSink) HandleSignal(signalId string, args core.Args) {
+	name := core.SymbolIdToMember(signalId)
+	s.mu.RLock()
+	handler, ok := s.handlers[name]
+	if index, isField := s.signals[name]; isField {
+		handler = s.target.Elem().FieldByIndex(index)
+		ok = !handler.IsNil()
+	}
+	s.mu.RUnlock()
+	if !ok {
+		log.Debug().Msgf("reflect sink %s: no handler for signal %s", s.objectId, name)
+		return
+	}
+	in, err := core.ConvertArgs(handler.Type(), args)
+	if err != nil {
+		log.Warn().Msgf("reflect sink %s: signal %s: %v", s.objectId, name, err)
+		return
+	}
+	handler.Call(in)
+}
+
+// HandleRelease unbinds the node.
+func (s *ReflectSink) HandleRelease() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.node = nil
+}
+
+// Invoke calls the remote method and waits for the reply.
+func (s *ReflectSink) Invoke(name string, args ...any) (core.Any, error) {
+	node := s.Node()
+	if node == nil {
+		return nil, fmt.Errorf("%s: not linked", s.objectId)
+	}
+	ch := make(chan InvokeReplyArg, 1)
+	requestId := node.invokeRemote(core.MakeSymbolId(s.objectId, name), core.Args(args), func(arg InvokeReplyArg) {
+		ch <- arg
+	})
+	var timeout <-chan time.Time
+	if s.InvokeTimeout > 0 {
+		timer := time.NewTimer(s.InvokeTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case arg := <-ch:
+		return arg.Value, arg.Error
+	case <-timeout:
+		node.cancelInvoke(requestId)
+		return nil, fmt.Errorf("%s/%s: invoke timed out after %s", s.objectId, name, s.InvokeTimeout)
+	}
+}
+
+// SetProperty requests a property change on the remote object.
+func (s *ReflectSink) SetProperty(name string, value any) error {
+	node := s.Node()
+	if node == nil {
+		return fmt.Errorf("%s: not linked", s.objectId)
+	}
+	node.SetRemoteProperty(core.MakeSymbolId(s.objectId, name), value)
+	return nil
+}
+
+// setField converts and sets a property field, the caller must hold the lock
+func (s *ReflectSink) setField(name string, value core.Any) {
+	index, ok := s.properties[name]
+	if !ok {
+		return
+	}
+	field := s.target.Elem().FieldByIndex(index)
+	v, err := core.ConvertValue(value, field.Type())
+	if err != nil {
+		log.Warn().Msgf("reflect sink %s: property %s: %v", s.objectId, name, err)
+		return
+	}
+	field.Set(v)
+}
+
+// makeInvoker creates a function of type ft which invokes the remote method.
+// The function may return nothing, a value, an error or a value and an error.
+func (s *ReflectSink) makeInvoker(name string, ft reflect.Type) (reflect.Value, error) {
+	if ft.Kind() != reflect.Func {
+		return reflect.Value{}, fmt.Errorf("must be a func")
+	}
+	if ft.IsVariadic() {
+		return reflect.Value{}, fmt.Errorf("variadic functions are not supported")
+	}
+	numOut := ft.NumOut()
+	hasErr := numOut > 0 && ft.Out(numOut-1) == errorType
+	numValues := numOut
+	if hasErr {
+		numValues--
+	}
+	if numValues > 1 {
+		return reflect.Value{}, fmt.Errorf("must return at most one value and an error")
+	}
+	fn := func(in []reflect.Value) []reflect.Value {
+		args := make([]any, len(in))
+		for i, v := range in {
+			args[i] = v.Interface()
+		}
+		result, err := s.Invoke(name, args...)
+		out := make([]reflect.Value, 0, numOut)
+		if numValues == 1 {
+			v := reflect.Zero(ft.Out(0))
+			if err == nil {
+				v, err = core.ConvertValue(result, ft.Out(0))
+				if err != nil {
+					v = reflect.Zero(ft.Out(0))
+				}
+			}
+			out = append(out, v)
+		}
+		if hasErr {
+			ev := reflect.Zero(errorType)
+			if err != nil {
+				ev = reflect.ValueOf(&err).Elem()
+			}
+			out = append(out, ev)
+		} else if err != nil {
+			log.Warn().Msgf("reflect sink %s: invoke %s: %v", s.objectId, name, err)
+		}
+		return out
+	}
+	return reflect.MakeFunc(ft, fn), nil
+}
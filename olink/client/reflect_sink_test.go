@@ -0,0 +1,131 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type reflectCounter struct {
+	Count     int64                           `olink:"count"`
+	Name      string                          `olink:"name"`
+	Increment func(step int64) (int64, error) `olink:"increment,method"`
+	Changed   func(count int64)               `olink:"changed,signal"`
+	resets    int
+}
+
+func (c *reflectCounter) OnReset() {
+	c.resets++
+}
+
+func writeMessage(t *testing.T, node *Node, msg core.Message) {
+	data, err := json.Marshal(msg)
+	assert.Nil(t, err)
+	node.Write(data)
+}
+
+func TestReflectSinkProperties(t *testing.T) {
+	registry := NewRegistry()
+	node := NewNode(registry)
+	node.SetOutput(core.NewMockDataWriter())
+	c := &reflectCounter{}
+	sink, err := NewReflectSink("demo.Counter", c)
+	assert.Nil(t, err)
+	registry.AddObjectSink(sink)
+	registry.LinkClientNode(sink.ObjectId(), node)
+	writeMessage(t, node, core.MakeInitMessage("demo.Counter", core.KWArgs{"count": 1, "name": "counter"}))
+	sink.Read(func() {
+		assert.Equal(t, int64(1), c.Count)
+		assert.Equal(t, "counter", c.Name)
+	})
+	assert.Equal(t, node, sink.Node())
+	writeMessage(t, node, core.MakePropertyChangeMessage("demo.Counter/count", 2))
+	sink.Read(func() {
+		assert.Equal(t, int64(2), c.Count)
+	})
+}
+
+func TestReflectSinkSignals(t *testing.T) {
+	c := &reflectCounter{}
+	var changed int64
+	c.Changed = func(count int64) {
+		changed = count
+	}
+	sink, err := NewReflectSink("demo.Counter", c)
+	assert.Nil(t, err)
+	sink.HandleSignal("demo.Counter/changed", core.Args{float64(3)})
+	assert.Equal(t, int64(3), changed)
+	sink.HandleSignal("demo.Counter/reset", core.Args{})
+	assert.Equal(t, 1, c.resets)
+}
+
+func TestReflectSinkInvoke(t *testing.T) {
+	registry := NewRegistry()
+	node := NewNode(registry)
+	writer := core.NewMockDataWriter()
+	node.SetOutput(writer)
+	c := &reflectCounter{}
+	sink, err := NewReflectSink("demo.Counter", c)
+	assert.Nil(t, err)
+	sink.InvokeTimeout = time.Second
+	// not linked yet
+	_, err = c.Increment(1)
+	assert.Error(t, err)
+	sink.HandleInit("demo.Counter", core.KWArgs{}, node)
+	done := make(chan int64)
+	go func() {
+		v, err := c.Increment(2)
+		assert.Nil(t, err)
+		done <- v
+	}()
+	assert.Eventually(t, func() bool { return node.pendingCount() == 1 }, time.Second, time.Millisecond)
+	writeMessage(t, node, core.MakeInvokeReplyMessage(1, "demo.Counter/increment", 2))
+	assert.Equal(t, int64(2), <-done)
+	go func() {
+		_, err := c.Increment(2)
+		assert.ErrorContains(t, err, "boom")
+		done <- 0
+	}()
+	assert.Eventually(t, func() bool { return node.pendingCount() == 1 }, time.Second, time.Millisecond)
+	writeMessage(t, node, core.MakeErrorMessage(core.MsgInvoke, 2, "boom"))
+	<-done
+}
+
+func TestReflectSinkInvokeTimeout(t *testing.T) {
+	registry := NewRegistry()
+	node := NewNode(registry)
+	node.SetOutput(core.NewMockDataWriter())
+	c := &reflectCounter{}
+	sink, err := NewReflectSink("demo.Counter", c)
+	assert.Nil(t, err)
+	sink.InvokeTimeout = 10 * time.Millisecond
+	sink.HandleInit("demo.Counter", core.KWArgs{}, node)
+	_, err = c.Increment(1)
+	assert.ErrorContains(t, err, "timed out")
+	// the timed out invoke is no longer pending
+	assert.Equal(t, 0, node.pendingCount())
+}
+
+func TestReflectSinkInvokeNodeClosed(t *testing.T) {
+	registry := NewRegistry()
+	node := NewNode(registry)
+	node.SetOutput(core.NewMockDataWriter())
+	c := &reflectCounter{}
+	sink, err := NewReflectSink("demo.Counter", c)
+	assert.Nil(t, err)
+	sink.HandleInit("demo.Counter", core.KWArgs{}, node)
+	done := make(chan error)
+	go func() {
+		_, err := c.Increment(1)
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return node.pendingCount() == 1 }, time.Second, time.Millisecond)
+	node.Close()
+	assert.ErrorIs(t, <-done, ErrNodeClosed)
+	// invokes on a closed node fail immediately
+	_, err = node.InvokeRemoteSync("demo.Counter/increment", core.Args{})
+	assert.ErrorIs(t, err, ErrNodeClosed)
+}
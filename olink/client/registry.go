@@ -59,6 +59,7 @@ func (r *Registry) DetachClientNode(node *Node) {
 func (r *Registry) LinkClientNode(objectId string, node *Node) {
 	log.Debug().Msgf("link client node to object %s", objectId)
 	r.entries.setNode(objectId, node)
+	r.entries.setState(objectId, LinkStateLinking)
 }
 
 func (r *Registry) UnlinkClientNode(objectId string) {
@@ -66,6 +67,22 @@ func (r *Registry) UnlinkClientNode(objectId string) {
 	r.entries.clearNode(objectId)
 }
 
+// LinkState returns the state of the link of the object.
+func (r *Registry) LinkState(objectId string) LinkState {
+	return r.entries.getState(objectId)
+}
+
+// releaseClientNode unlinks the object after the remote side removed it
+// and releases the sink.
+func (r *Registry) releaseClientNode(objectId string) {
+	log.Debug().Msgf("release client node from object %s", objectId)
+	r.entries.clearNode(objectId)
+	r.entries.setState(objectId, LinkStateReleased)
+	if s := r.entries.getSink(objectId); s != nil {
+		s.HandleRelease()
+	}
+}
+
 func (r *Registry) GetClientNode(objectId string) *Node {
 	return r.entries.getNode(objectId)
 }
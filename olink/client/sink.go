@@ -11,3 +11,10 @@ type IObjectSink interface {
 	HandleInit(objectId string, props core.KWArgs, node *Node)
 	HandleRelease()
 }
+
+// IBatchSink is an optional interface for sinks which apply batched
+// property changes together. Other sinks receive one
+// HandlePropertyChange call per property of a batch.
+type IBatchSink interface {
+	HandlePropertyChanges(objectId string, props core.KWArgs)
+}
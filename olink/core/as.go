@@ -13,6 +13,10 @@ func AsBool(v any) bool {
 		return v
 	case int:
 		return v != 0
+	case int64:
+		return v != 0
+	case uint64:
+		return v != 0
 	case float64:
 		return v != 0
 	default:
@@ -27,6 +31,10 @@ func AsFloat(v any) float64 {
 		return v
 	case int:
 		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
 	case bool:
 		if v {
 			return 1
@@ -51,6 +59,8 @@ func AsInt(v any) int64 {
 		return int64(v)
 	case int64:
 		return v
+	case uint64:
+		return int64(v)
 	case int:
 		return int64(v)
 	case json.Number:
@@ -73,6 +83,8 @@ func AsArgs(v any) Args {
 	switch v := v.(type) {
 	case []any:
 		return v
+	case Args:
+		return v
 	default:
 		log.Warn().Msgf("as args unknown type %#v %T", v, v)
 		return nil
@@ -126,6 +138,8 @@ func AsProps(v any) KWArgs {
 	switch v := v.(type) {
 	case map[string]any:
 		return v
+	case KWArgs:
+		return v
 	default:
 		log.Warn().Msgf("as props unknown type %#v %T", v, v)
 		return nil
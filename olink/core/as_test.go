@@ -11,6 +11,8 @@ func TestAsBool(t *testing.T) {
 	assert.False(t, AsBool(nil))
 	assert.False(t, AsBool(0))
 	assert.False(t, AsBool(0.0))
+	assert.True(t, AsBool(int64(1)))
+	assert.True(t, AsBool(uint64(1)))
 }
 
 func TestAsFloat(t *testing.T) {
@@ -19,6 +21,8 @@ func TestAsFloat(t *testing.T) {
 	assert.Equal(t, 0.0, AsFloat(0))
 	assert.Equal(t, 0.0, AsFloat(0.0))
 	assert.Equal(t, 1.0, AsFloat(1))
+	assert.Equal(t, 2.0, AsFloat(int64(2)))
+	assert.Equal(t, 3.0, AsFloat(uint64(3)))
 }
 
 func TestAsInt(t *testing.T) {
@@ -27,6 +31,7 @@ func TestAsInt(t *testing.T) {
 	assert.Equal(t, int64(0), AsInt(0))
 	assert.Equal(t, int64(0), AsInt(0.0))
 	assert.Equal(t, int64(1), AsInt(1))
+	assert.Equal(t, int64(2), AsInt(uint64(2)))
 }
 
 func TestAsArgs(t *testing.T) {
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryMaxDepth(t *testing.T) {
+	cases := []struct {
+		format MessageFormat
+		nest   byte
+		empty  byte
+	}{
+		{FormatMsgPack, 0x91, 0x90},
+		{FormatCbor, 0x81, 0x80},
+	}
+	for _, c := range cases {
+		conv := NewConverter(c.format)
+		data := append(bytes.Repeat([]byte{c.nest}, maxBinaryDepth-1), c.empty)
+		_, err := conv.FromData(data)
+		assert.Nil(t, err, c.format.String())
+		data = append(bytes.Repeat([]byte{c.nest}, maxBinaryDepth), c.empty)
+		_, err = conv.FromData(data)
+		assert.ErrorContains(t, err, "max depth", c.format.String())
+		// a large payload fails fast instead of exhausting the stack
+		_, err = conv.FromData(bytes.Repeat([]byte{c.nest}, 1<<20))
+		assert.ErrorContains(t, err, "max depth", c.format.String())
+	}
+	// nested cbor tags count as well
+	data := append(bytes.Repeat([]byte{0xc1}, maxBinaryDepth+1), 0x80)
+	_, err := decodeCbor(append([]byte{0x81}, data...))
+	assert.ErrorContains(t, err, "max depth")
+}
+
+// fuzzBinary checks that decoding arbitrary data does not panic
+// and that a decoded message survives an encode and decode round trip
+func fuzzBinary(f *testing.F, format MessageFormat) {
+	conv := NewConverter(format)
+	for _, msg := range []Message{
+		MakeLinkMessage("demo.Counter"),
+		MakeInitMessage("demo.Counter", KWArgs{"count": 3, "list": []any{1.5, "a", nil, true}}),
+		MakeInvokeMessage(300000, "demo.Counter/add", Args{-1, -70000, []byte{1, 2}}),
+	} {
+		data, err := conv.ToData(msg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := conv.FromData(data)
+		if err != nil {
+			return
+		}
+		out, err := conv.ToData(msg)
+		if err != nil {
+			t.Fatalf("encode decoded message: %v", err)
+		}
+		again, err := conv.FromData(out)
+		if err != nil {
+			t.Fatalf("decode encoded message: %v", err)
+		}
+		if fmt.Sprint(msg) != fmt.Sprint(again) {
+			t.Fatalf("round trip changed message: %v != %v", msg, again)
+		}
+	})
+}
+
+func FuzzMsgPack(f *testing.F) {
+	fuzzBinary(f, FormatMsgPack)
+}
+
+func FuzzCbor(f *testing.F) {
+	fuzzBinary(f, FormatCbor)
+}
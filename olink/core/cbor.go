@@ -0,0 +1,243 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// encodeCbor encodes the message as CBOR
+func encodeCbor(msg Message) ([]byte, error) {
+	v, err := toGeneric([]any(msg))
+	if err != nil {
+		return nil, err
+	}
+	return appendCbor(nil, v)
+}
+
+// appendCborHead appends the major type and argument in the shortest encoding
+func appendCborHead(buf []byte, major byte, v uint64) []byte {
+	m := major << 5
+	switch {
+	case v < 24:
+		return append(buf, m|byte(v))
+	case v <= math.MaxUint8:
+		return append(buf, m|24, byte(v))
+	case v <= math.MaxUint16:
+		return putUint(append(buf, m|25), v, 2)
+	case v <= math.MaxUint32:
+		return putUint(append(buf, m|26), v, 4)
+	}
+	return putUint(append(buf, m|27), v, 8)
+}
+
+// appendCbor appends the CBOR encoding of a generic value
+func appendCbor(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if v {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case int64:
+		if v >= 0 {
+			return appendCborHead(buf, cborUint, uint64(v)), nil
+		}
+		return appendCborHead(buf, cborNegInt, uint64(-1-v)), nil
+	case uint64:
+		return appendCborHead(buf, cborUint, v), nil
+	case float64:
+		return putUint(append(buf, 0xfb), math.Float64bits(v), 8), nil
+	case string:
+		return append(appendCborHead(buf, cborText, uint64(len(v))), v...), nil
+	case []byte:
+		return append(appendCborHead(buf, cborBytes, uint64(len(v))), v...), nil
+	case []any:
+		buf = appendCborHead(buf, cborArray, uint64(len(v)))
+		var err error
+		for _, e := range v {
+			buf, err = appendCbor(buf, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		buf = appendCborHead(buf, cborMap, uint64(len(v)))
+		var err error
+		for k, e := range v {
+			buf, _ = appendCbor(buf, k)
+			buf, err = appendCbor(buf, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cbor: cannot encode %T", v)
+}
+
+// decodeCbor decodes a CBOR encoded message.
+// Integers are decoded as int64, or as uint64 if they exceed int64,
+// so large values keep their precision. Integers below the int64 range
+// and floats are decoded as float64.
+// Tags are ignored and indefinite lengths are not supported.
+func decodeCbor(data []byte) (Message, error) {
+	r := &binaryReader{data: data}
+	v, err := readCbor(r)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("cbor: %d trailing bytes", len(data)-r.pos)
+	}
+	msg, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cbor: message is not an array")
+	}
+	return msg, nil
+}
+
+// readCbor reads the next value
+func readCbor(r *binaryReader) (any, error) {
+	b, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := b>>5, b&0x1f
+	if major == cborSimple {
+		return readCborSimple(r, info)
+	}
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		arg, err = r.uint(1 << (info - 24))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported additional information %d", info)
+	}
+	if major == cborArray || major == cborMap || major == cborTag {
+		if err := r.enter(); err != nil {
+			return nil, err
+		}
+		defer r.leave()
+	}
+	switch major {
+	case cborUint:
+		return unsignedValue(arg), nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			// below the int64 range
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		size, err := r.checkLength(arg)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(size)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(data), nil
+		}
+		return append([]byte(nil), data...), nil
+	case cborArray:
+		size, err := r.checkLength(arg)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, size)
+		for i := range out {
+			out[i], err = readCbor(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case cborMap:
+		size, err := r.checkLength(arg)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]any, size)
+		for i := 0; i < size; i++ {
+			k, err := readCbor(r)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("map key is not a string")
+			}
+			out[key], err = readCbor(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	// tags carry the tagged value
+	return readCbor(r)
+}
+
+// readCborSimple reads simple values and floats
+func readCborSimple(r *binaryReader, info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		v, err := r.uint(2)
+		return halfToFloat(uint16(v)), err
+	case 26:
+		v, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 27:
+		v, err := r.uint(8)
+		return math.Float64frombits(v), err
+	}
+	return nil, fmt.Errorf("unsupported simple value %d", info)
+}
+
+// halfToFloat converts an IEEE 754 half precision float
+func halfToFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 )
 
 type MessageFormat int
@@ -14,6 +15,65 @@ const (
 	FormatCbor    MessageFormat = 4
 )
 
+func (f MessageFormat) String() string {
+	switch f {
+	case FormatJson:
+		return "json"
+	case FormatBson:
+		return "bson"
+	case FormatMsgPack:
+		return "msgpack"
+	case FormatCbor:
+		return "cbor"
+	}
+	return fmt.Sprintf("format(%d)", int(f))
+}
+
+// FrameType returns the frame type used to transport messages of the format.
+// JSON is sent as text, all other formats as binary frames.
+func (f MessageFormat) FrameType() FrameType {
+	if f == FormatJson {
+		return FrameText
+	}
+	return FrameBinary
+}
+
+// subprotocolPrefix is the prefix of the websocket subprotocol names
+const subprotocolPrefix = "olink."
+
+// Subprotocol returns the websocket subprotocol name of the format, e.g. "olink.json".
+func (f MessageFormat) Subprotocol() string {
+	return subprotocolPrefix + f.String()
+}
+
+// FormatFromSubprotocol returns the format of a websocket subprotocol name.
+func FormatFromSubprotocol(name string) (MessageFormat, bool) {
+	for _, f := range []MessageFormat{FormatJson, FormatBson, FormatMsgPack, FormatCbor} {
+		if f.Subprotocol() == name {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// IsSupported returns true if the converter can encode and decode the format.
+func (f MessageFormat) IsSupported() bool {
+	switch f {
+	case FormatJson, FormatMsgPack, FormatCbor:
+		return true
+	}
+	return false
+}
+
+// UnsupportedFormatError is returned by the converter for formats without encoder.
+type UnsupportedFormatError struct {
+	Format MessageFormat
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported message format %s", e.Format)
+}
+
 type MessageConverter struct {
 	Format MessageFormat
 }
@@ -29,8 +89,12 @@ func (c *MessageConverter) ToData(msg Message) ([]byte, error) {
 	case FormatJson:
 		data, err := json.Marshal(msg)
 		return data, err
+	case FormatMsgPack:
+		return encodeMsgPack(msg)
+	case FormatCbor:
+		return encodeCbor(msg)
 	}
-	return nil, nil
+	return nil, &UnsupportedFormatError{Format: c.Format}
 }
 
 func (c *MessageConverter) FromData(data []byte) (Message, error) {
@@ -41,6 +105,10 @@ func (c *MessageConverter) FromData(data []byte) (Message, error) {
 		// decoder.UseNumber()
 		err := decoder.Decode(&msg)
 		return msg, err
+	case FormatMsgPack:
+		return decodeMsgPack(data)
+	case FormatCbor:
+		return decodeCbor(data)
 	}
-	return nil, nil
+	return nil, &UnsupportedFormatError{Format: c.Format}
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,3 +17,132 @@ func TestConverter(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, msg.AsLink(), act.AsLink())
 }
+
+func TestConverterUnsupportedFormat(t *testing.T) {
+	c := NewConverter(FormatBson)
+	_, err := c.ToData(MakeLinkMessage("test"))
+	assert.ErrorContains(t, err, "unsupported message format bson")
+	_, err = c.FromData([]byte{0x80})
+	assert.Error(t, err)
+}
+
+func TestFormatFrameType(t *testing.T) {
+	assert.Equal(t, FrameText, FormatJson.FrameType())
+	assert.Equal(t, FrameBinary, FormatMsgPack.FrameType())
+	assert.Equal(t, FrameBinary, FormatCbor.FrameType())
+}
+
+// frameRecorder records the frame types of written data
+type frameRecorder struct {
+	frames []FrameType
+}
+
+func (r *frameRecorder) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (r *frameRecorder) WriteFrame(frame FrameType, data []byte) (int, error) {
+	r.frames = append(r.frames, frame)
+	return len(data), nil
+}
+
+func TestWriteFrame(t *testing.T) {
+	r := &frameRecorder{}
+	_, err := WriteFrame(r, FrameBinary, []byte{1})
+	assert.Nil(t, err)
+	assert.Equal(t, []FrameType{FrameBinary}, r.frames)
+}
+
+func TestBinaryConverters(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	msgs := []Message{
+		MakeLinkMessage("demo.Counter"),
+		MakeInitMessage("demo.Counter", KWArgs{"count": 3, "name": "c", "ok": true, "none": nil, "ratio": 0.5}),
+		MakeInvokeMessage(300000, "demo.Counter/add", Args{-1, -200, -70000, int64(-1) << 40, uint8(200), []string{"a"}, point{1, 2}}),
+		MakeSignalMessage("demo.Counter/big", Args{string(make([]byte, 300)), make([]any, 20)}),
+	}
+	for _, format := range []MessageFormat{FormatMsgPack, FormatCbor} {
+		c := NewConverter(format)
+		j := NewConverter(FormatJson)
+		for _, msg := range msgs {
+			data, err := c.ToData(msg)
+			assert.Nil(t, err)
+			act, err := c.FromData(data)
+			assert.Nil(t, err)
+			// binary formats decode like json, but keep integers
+			jdata, err := j.ToData(msg)
+			assert.Nil(t, err)
+			exp, err := j.FromData(jdata)
+			assert.Nil(t, err)
+			assert.Equal(t, wholeToInt(exp), act, format.String())
+		}
+		_, err := c.FromData([]byte{0x01})
+		assert.Error(t, err)
+		_, err = c.FromData(nil)
+		assert.Error(t, err)
+	}
+}
+
+// wholeToInt converts the whole numbers decoded by json to int64
+func wholeToInt(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v)
+		}
+	case Message:
+		return Message(wholeToInt([]any(v)).([]any))
+	case []any:
+		for i, e := range v {
+			v[i] = wholeToInt(e)
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = wholeToInt(e)
+		}
+	}
+	return v
+}
+
+func TestBinaryIntegers(t *testing.T) {
+	args := Args{int64(math.MaxInt64), int64(math.MinInt64), uint64(math.MaxUint64), int64(1<<53 + 1), -1, 0, 1.5}
+	exp := Message{int64(MsgSignal), "demo.Counter/big", []any{int64(math.MaxInt64), int64(math.MinInt64), uint64(math.MaxUint64), int64(1<<53 + 1), int64(-1), int64(0), 1.5}}
+	for _, format := range []MessageFormat{FormatMsgPack, FormatCbor} {
+		c := NewConverter(format)
+		data, err := c.ToData(MakeSignalMessage("demo.Counter/big", args))
+		assert.Nil(t, err)
+		act, err := c.FromData(data)
+		assert.Nil(t, err)
+		// integers keep their precision
+		assert.Equal(t, exp, act, format.String())
+	}
+	// cbor integers below the int64 range are decoded as float
+	msg, err := decodeCbor([]byte{0x81, 0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.Nil(t, err)
+	assert.Equal(t, Message{-1 - float64(math.MaxUint64)}, msg)
+}
+
+func TestMsgPackEncodeError(t *testing.T) {
+	_, err := appendMsgPack(nil, map[string]any{"a": []any{struct{}{}}})
+	assert.ErrorContains(t, err, "cannot encode")
+	_, err = appendMsgPack(nil, []any{map[string]any{"a": int8(1)}})
+	assert.ErrorContains(t, err, "cannot encode int8")
+}
+
+func TestCborHalfFloat(t *testing.T) {
+	msg, err := decodeCbor([]byte{0x81, 0xf9, 0x3c, 0x00})
+	assert.Nil(t, err)
+	assert.Equal(t, Message{float64(1)}, msg)
+}
+
+func TestSubprotocol(t *testing.T) {
+	assert.Equal(t, "olink.msgpack", FormatMsgPack.Subprotocol())
+	f, ok := FormatFromSubprotocol("olink.cbor")
+	assert.True(t, ok)
+	assert.Equal(t, FormatCbor, f)
+	_, ok = FormatFromSubprotocol("other")
+	assert.False(t, ok)
+}
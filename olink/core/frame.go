@@ -0,0 +1,37 @@
+package core
+
+import (
+	"io"
+)
+
+// FrameType is the type of a transport frame carrying an encoded message.
+type FrameType int
+
+const (
+	FrameText   FrameType = 1
+	FrameBinary FrameType = 2
+)
+
+func (f FrameType) String() string {
+	switch f {
+	case FrameText:
+		return "text"
+	case FrameBinary:
+		return "binary"
+	}
+	return "unknown"
+}
+
+// FrameWriter is implemented by writers which distinguish text and binary frames.
+type FrameWriter interface {
+	WriteFrame(frame FrameType, data []byte) (int, error)
+}
+
+// WriteFrame writes the data as frame if the writer is a FrameWriter,
+// otherwise the data is written using Write.
+func WriteFrame(w io.Writer, frame FrameType, data []byte) (int, error) {
+	if fw, ok := w.(FrameWriter); ok {
+		return fw.WriteFrame(frame, data)
+	}
+	return w.Write(data)
+}
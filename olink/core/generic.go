@@ -0,0 +1,173 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// toGeneric converts a value into the generic types nil, bool, int64, uint64,
+// float64, string, []byte, []any and map[string]any used by the binary codecs.
+// Values of other types are converted using a json round trip.
+func toGeneric(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, int64, uint64, float64, string, []byte:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case MsgType:
+		return int64(v), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return toGeneric(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			e, err := toGeneric(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[i] = e
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			if rv.IsNil() {
+				return nil, nil
+			}
+			out := make(map[string]any, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				e, err := toGeneric(iter.Value().Interface())
+				if err != nil {
+					return nil, err
+				}
+				out[iter.Key().String()] = e
+			}
+			return out, nil
+		}
+	}
+	// structs and other types use their json representation
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %T: %w", v, err)
+	}
+	// decode numbers as json.Number, so integers are encoded as integers
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var out any
+	err = d.Decode(&out)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %T: %w", v, err)
+	}
+	return toGeneric(out)
+}
+
+// maxBinaryDepth is the maximum nesting depth of arrays, maps and tags
+// accepted by the binary codecs, the same limit as encoding/json
+const maxBinaryDepth = 10000
+
+// binaryReader reads the input of the binary codecs
+type binaryReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// enter increases the nesting depth before reading a nested value
+func (r *binaryReader) enter() error {
+	r.depth++
+	if r.depth > maxBinaryDepth {
+		return fmt.Errorf("exceeded max depth %d", maxBinaryDepth)
+	}
+	return nil
+}
+
+// leave decreases the nesting depth after reading a nested value
+func (r *binaryReader) leave() {
+	r.depth--
+}
+
+// next returns the next n bytes
+func (r *binaryReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// byte returns the next byte
+func (r *binaryReader) byte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// uint returns the next n bytes as big endian unsigned integer
+func (r *binaryReader) uint(n int) (uint64, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// checkLength returns n if the remaining data can hold n bytes or elements
+func (r *binaryReader) checkLength(n uint64) (int, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return 0, fmt.Errorf("length %d exceeds data", n)
+	}
+	return int(n), nil
+}
+
+// unsignedValue returns a decoded unsigned integer as int64,
+// or as uint64 if it exceeds the int64 range
+func unsignedValue(v uint64) any {
+	if v > math.MaxInt64 {
+		return v
+	}
+	return int64(v)
+}
+
+// putUint appends v as big endian unsigned integer with n bytes
+func putUint(buf []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
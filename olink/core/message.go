@@ -10,6 +10,8 @@ func (t MsgType) String() string {
 	switch t {
 	case MsgUnknown:
 		return "unknown"
+	case MsgFeatures:
+		return "features"
 	case MsgLink:
 		return "link"
 	case MsgInit:
@@ -20,6 +22,8 @@ func (t MsgType) String() string {
 		return "set"
 	case MsgPropertyChange:
 		return "change"
+	case MsgPropertyChanges:
+		return "changes"
 	case MsgInvoke:
 		return "invoke"
 	case MsgInvokeReply:
@@ -36,6 +40,8 @@ func MsgTypeFromString(s string) MsgType {
 	switch s {
 	case "unknown":
 		return MsgUnknown
+	case "features":
+		return MsgFeatures
 	case "link":
 		return MsgLink
 	case "init":
@@ -46,6 +52,8 @@ func MsgTypeFromString(s string) MsgType {
 		return MsgSetProperty
 	case "change":
 		return MsgPropertyChange
+	case "changes":
+		return MsgPropertyChanges
 	case "invoke":
 		return MsgInvoke
 	case "reply":
@@ -59,16 +67,29 @@ func MsgTypeFromString(s string) MsgType {
 }
 
 const (
-	MsgUnknown        MsgType = 0
-	MsgLink           MsgType = 10
-	MsgInit           MsgType = 11
-	MsgUnlink         MsgType = 12
-	MsgSetProperty    MsgType = 20
-	MsgPropertyChange MsgType = 21
-	MsgInvoke         MsgType = 30
-	MsgInvokeReply    MsgType = 31
-	MsgSignal         MsgType = 40
-	MsgError          MsgType = 90
+	MsgUnknown         MsgType = 0
+	MsgFeatures        MsgType = 2
+	MsgLink            MsgType = 10
+	MsgInit            MsgType = 11
+	MsgUnlink          MsgType = 12
+	MsgSetProperty     MsgType = 20
+	MsgPropertyChange  MsgType = 21
+	MsgPropertyChanges MsgType = 22
+	MsgInvoke          MsgType = 30
+	MsgInvokeReply     MsgType = 31
+	MsgSignal          MsgType = 40
+	MsgError           MsgType = 90
+)
+
+// Protocol features negotiated using the features message.
+// The client sends the features it supports, the remote side
+// replies with the features it accepted.
+const (
+	// FeatureBatchedChanges enables the batched property changes message
+	FeatureBatchedChanges = "batch"
+	// FeatureRemoteUnlink enables unlink messages sent by the remote side
+	// when an object is removed
+	FeatureRemoteUnlink = "unlink"
 )
 
 type Args []any
@@ -133,6 +154,18 @@ func (m Message) AsPropertyChange() (string, Any) {
 	return AsString(m[1]), AsAny(m[2])
 }
 
+// AsPropertyChanges returns the object id and the changed properties
+// message := MsgType, ObjectId, Props
+func (m Message) AsPropertyChanges() (string, KWArgs) {
+	return AsString(m[1]), AsProps(m[2])
+}
+
+// AsFeatures returns the list of features
+// message := MsgType, Features
+func (m Message) AsFeatures() []string {
+	return AsArrayString(m[1])
+}
+
 // AsInvoke returns the id, name and args of the invoke message
 // message := MsgType, RequestId, MethodId, Args
 func (m Message) AsInvoke() (int64, string, Args) {
@@ -151,8 +184,11 @@ func (m Message) AsSignal() (string, Args) {
 	return AsString(m[1]), AsArgs(m[2])
 }
 
+// AsError returns the failed message type, the request id and the error
+// message := MsgType, MsgType, RequestId, Error
+// The first element is always MsgError, the failed message type follows it.
 func (m Message) AsError() (MsgType, int64, string) {
-	return AsMsgType(m[0]), AsInt(m[1]), AsString(m[2])
+	return AsMsgType(m[1]), AsInt(m[2]), AsString(m[3])
 }
 
 func MakeLinkMessage(objectId string) Message {
@@ -193,6 +229,21 @@ func MakePropertyChangeMessage(propertyId string, value Any) Message {
 	}
 }
 
+func MakePropertyChangesMessage(objectId string, props KWArgs) Message {
+	return Message{
+		MsgPropertyChanges,
+		objectId,
+		props,
+	}
+}
+
+func MakeFeaturesMessage(features []string) Message {
+	return Message{
+		MsgFeatures,
+		features,
+	}
+}
+
 func MakeInvokeMessage(requestId int64, methodId string, args Args) Message {
 	return Message{
 		MsgInvoke,
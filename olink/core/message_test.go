@@ -83,4 +83,34 @@ func TestSignal(t *testing.T) {
 func TestError(t *testing.T) {
 	msg := MakeErrorMessage(data.MsgType, data.RequestId, data.ErrorMessage)
 	assert.Equal(t, Message{MsgError, data.MsgType, data.RequestId, data.ErrorMessage}, msg)
+	msgType, requestId, err := msg.AsError()
+	assert.Equal(t, data.MsgType, msgType)
+	assert.Equal(t, data.RequestId, requestId)
+	assert.Equal(t, data.ErrorMessage, err)
+}
+
+func TestErrorFromWire(t *testing.T) {
+	// error messages decoded from JSON carry numbers as float64
+	conv := MessageConverter{Format: FormatJson}
+	msg, err := conv.FromData([]byte(`[90, 30, 7, "boom"]`))
+	assert.NoError(t, err)
+	msgType, requestId, reason := msg.AsError()
+	assert.Equal(t, MsgInvoke, msgType)
+	assert.Equal(t, int64(7), requestId)
+	assert.Equal(t, "boom", reason)
+}
+
+func TestPropertyChanges(t *testing.T) {
+	msg := MakePropertyChangesMessage(data.ObjectId, data.Props)
+	assert.Equal(t, Message{MsgPropertyChanges, data.ObjectId, data.Props}, msg)
+	objectId, props := msg.AsPropertyChanges()
+	assert.Equal(t, data.ObjectId, objectId)
+	assert.Equal(t, data.Props, props)
+}
+
+func TestFeatures(t *testing.T) {
+	msg := MakeFeaturesMessage([]string{FeatureBatchedChanges})
+	assert.Equal(t, Message{MsgFeatures, []string{FeatureBatchedChanges}}, msg)
+	assert.Equal(t, []string{FeatureBatchedChanges}, msg.AsFeatures())
+	assert.Equal(t, MsgPropertyChanges, MsgTypeFromString(MsgPropertyChanges.String()))
 }
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// encodeMsgPack encodes the message as MessagePack
+func encodeMsgPack(msg Message) ([]byte, error) {
+	v, err := toGeneric([]any(msg))
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPack(nil, v)
+}
+
+// appendMsgPack appends the MessagePack encoding of a generic value
+func appendMsgPack(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case int64:
+		switch {
+		case v >= 0:
+			return appendMsgPackUint(buf, uint64(v)), nil
+		case v >= -32:
+			return append(buf, byte(v)), nil
+		case v >= math.MinInt8:
+			return append(buf, 0xd0, byte(v)), nil
+		case v >= math.MinInt16:
+			return putUint(append(buf, 0xd1), uint64(v), 2), nil
+		case v >= math.MinInt32:
+			return putUint(append(buf, 0xd2), uint64(v), 4), nil
+		}
+		return putUint(append(buf, 0xd3), uint64(v), 8), nil
+	case uint64:
+		return appendMsgPackUint(buf, v), nil
+	case float64:
+		return putUint(append(buf, 0xcb), math.Float64bits(v), 8), nil
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf = append(buf, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			buf = putUint(append(buf, 0xda), uint64(n), 2)
+		default:
+			buf = putUint(append(buf, 0xdb), uint64(n), 4)
+		}
+		return append(buf, v...), nil
+	case []byte:
+		n := len(v)
+		switch {
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			buf = putUint(append(buf, 0xc5), uint64(n), 2)
+		default:
+			buf = putUint(append(buf, 0xc6), uint64(n), 4)
+		}
+		return append(buf, v...), nil
+	case []any:
+		n := len(v)
+		switch {
+		case n < 16:
+			buf = append(buf, 0x90|byte(n))
+		case n <= math.MaxUint16:
+			buf = putUint(append(buf, 0xdc), uint64(n), 2)
+		default:
+			buf = putUint(append(buf, 0xdd), uint64(n), 4)
+		}
+		var err error
+		for _, e := range v {
+			buf, err = appendMsgPack(buf, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		n := len(v)
+		switch {
+		case n < 16:
+			buf = append(buf, 0x80|byte(n))
+		case n <= math.MaxUint16:
+			buf = putUint(append(buf, 0xde), uint64(n), 2)
+		default:
+			buf = putUint(append(buf, 0xdf), uint64(n), 4)
+		}
+		var err error
+		for k, e := range v {
+			buf, err = appendMsgPack(buf, k)
+			if err != nil {
+				return nil, err
+			}
+			buf, err = appendMsgPack(buf, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("msgpack: cannot encode %T", v)
+}
+
+// appendMsgPackUint appends an unsigned integer in the shortest encoding
+func appendMsgPackUint(buf []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(buf, byte(v))
+	case v <= math.MaxUint8:
+		return append(buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return putUint(append(buf, 0xcd), v, 2)
+	case v <= math.MaxUint32:
+		return putUint(append(buf, 0xce), v, 4)
+	}
+	return putUint(append(buf, 0xcf), v, 8)
+}
+
+// decodeMsgPack decodes a MessagePack encoded message.
+// Integers are decoded as int64, or as uint64 if they exceed int64,
+// so large values keep their precision. Floats are decoded as float64.
+func decodeMsgPack(data []byte) (Message, error) {
+	r := &binaryReader{data: data}
+	v, err := readMsgPack(r)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(data)-r.pos)
+	}
+	msg, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("msgpack: message is not an array")
+	}
+	return msg, nil
+}
+
+// readMsgPack reads the next value
+func readMsgPack(r *binaryReader) (any, error) {
+	b, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return readMsgPackMap(r, uint64(b&0x0f))
+	case b&0xf0 == 0x90:
+		return readMsgPackArray(r, uint64(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return readMsgPackString(r, uint64(b&0x1f))
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.checkLength(n)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(size)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), data...), nil
+	case 0xca:
+		v, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := r.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := r.uint(1 << (b - 0xcc))
+		return unsignedValue(v), err
+	case 0xd0:
+		v, err := r.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := r.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := r.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := r.uint(8)
+		return int64(v), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackString(r, n)
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackMap(r, n)
+	}
+	return nil, fmt.Errorf("unsupported type 0x%02x", b)
+}
+
+func readMsgPackString(r *binaryReader, n uint64) (any, error) {
+	size, err := r.checkLength(n)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.next(size)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func readMsgPackArray(r *binaryReader, n uint64) (any, error) {
+	size, err := r.checkLength(n)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	out := make([]any, size)
+	for i := range out {
+		out[i], err = readMsgPack(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func readMsgPackMap(r *binaryReader, n uint64) (any, error) {
+	size, err := r.checkLength(n)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	out := make(map[string]any, size)
+	for i := 0; i < size; i++ {
+		k, err := readMsgPack(r)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key is not a string")
+		}
+		out[key], err = readMsgPack(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TagName is the struct tag key used to map go struct fields to object members,
+// e.g. `olink:"count"` or `olink:"count,readonly"`.
+const TagName = "olink"
+
+// ParseTag returns the member name and the options of an olink struct tag.
+func ParseTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	return strings.TrimSpace(parts[0]), parts[1:]
+}
+
+// HasTagOption returns true if the option is part of the tag options.
+func HasTagOption(opts []string, option string) bool {
+	for _, o := range opts {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// MemberName returns the member name for a go identifier,
+// which is the identifier with a lower case first letter (e.g. Increment -> increment).
+func MemberName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	if r == utf8.RuneError {
+		return name
+	}
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,19 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTag(t *testing.T) {
+	t.Parallel()
+	name, opts := ParseTag("count,readonly")
+	assert.Equal(t, "count", name)
+	assert.True(t, HasTagOption(opts, "readonly"))
+	name, opts = ParseTag("count")
+	assert.Equal(t, "count", name)
+	assert.False(t, HasTagOption(opts, "readonly"))
+	assert.Equal(t, "increment", MemberName("Increment"))
+	assert.Equal(t, "getCount", MemberName("GetCount"))
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ConvertValue converts a decoded protocol value into a value of type t.
+// Numbers are converted between integer and float types if no precision is lost,
+// slices, maps and structs are converted using a json round trip.
+func ConvertValue(v Any, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(t), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(t) {
+		return rv, nil
+	}
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := valueToInt(v)
+		if !ok || out.OverflowInt(i) {
+			return out, conversionError(v, t)
+		}
+		out.SetInt(i)
+		return out, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := valueToInt(v)
+		if !ok || i < 0 || out.OverflowUint(uint64(i)) {
+			return out, conversionError(v, t)
+		}
+		out.SetUint(uint64(i))
+		return out, nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := valueToFloat(v)
+		if !ok || out.OverflowFloat(f) {
+			return out, conversionError(v, t)
+		}
+		out.SetFloat(f)
+		return out, nil
+	case reflect.String, reflect.Bool:
+		if rv.Kind() != t.Kind() {
+			return out, conversionError(v, t)
+		}
+		return rv.Convert(t), nil
+	}
+	// fall back to a json round trip for composite types
+	data, err := json.Marshal(v)
+	if err != nil {
+		return out, fmt.Errorf("cannot convert %T to %s: %w", v, t, err)
+	}
+	ptr := reflect.New(t)
+	err = json.Unmarshal(data, ptr.Interface())
+	if err != nil {
+		return out, fmt.Errorf("cannot convert %T to %s: %w", v, t, err)
+	}
+	return ptr.Elem(), nil
+}
+
+// ConvertArgs converts the arguments to the parameters of the function type ft.
+func ConvertArgs(ft reflect.Type, args Args) ([]reflect.Value, error) {
+	if ft.IsVariadic() {
+		return nil, fmt.Errorf("variadic functions are not supported")
+	}
+	if len(args) != ft.NumIn() {
+		return nil, fmt.Errorf("expected %d arguments, got %d", ft.NumIn(), len(args))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		v, err := ConvertValue(arg, ft.In(i))
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		in[i] = v
+	}
+	return in, nil
+}
+
+func conversionError(v Any, t reflect.Type) error {
+	return fmt.Errorf("cannot convert %T(%v) to %s", v, v, t)
+}
+
+// valueToInt returns the value as int64 if it is an integral number
+func valueToInt(v Any) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		return int64(u), u <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// valueToFloat returns the value as float64 if it is a number
+func valueToFloat(v Any) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertValue(t *testing.T) {
+	t.Parallel()
+	v, err := ConvertValue(float64(10), reflect.TypeOf(int64(0)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), v.Interface())
+	v, err = ConvertValue(int64(3), reflect.TypeOf(float32(0)))
+	assert.NoError(t, err)
+	assert.Equal(t, float32(3), v.Interface())
+	v, err = ConvertValue(nil, reflect.TypeOf(""))
+	assert.NoError(t, err)
+	assert.Equal(t, "", v.Interface())
+	v, err = ConvertValue([]any{1.0, 2.0}, reflect.TypeOf([]int{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, v.Interface())
+	type point struct {
+		X int `json:"x"`
+	}
+	v, err = ConvertValue(map[string]any{"x": 1.0}, reflect.TypeOf(point{}))
+	assert.NoError(t, err)
+	assert.Equal(t, point{X: 1}, v.Interface())
+}
+
+func TestConvertValueErrors(t *testing.T) {
+	t.Parallel()
+	_, err := ConvertValue(1.5, reflect.TypeOf(int64(0)))
+	assert.Error(t, err)
+	_, err = ConvertValue(300.0, reflect.TypeOf(uint8(0)))
+	assert.Error(t, err)
+	_, err = ConvertValue(-1.0, reflect.TypeOf(uint(0)))
+	assert.Error(t, err)
+	_, err = ConvertValue("1", reflect.TypeOf(int(0)))
+	assert.Error(t, err)
+	_, err = ConvertValue(1, reflect.TypeOf(""))
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package remote
+
+import "context"
+
+// Principal identifies the authenticated peer behind a node.
+type Principal struct {
+	// Name is the identity of the peer, e.g. a user or service name.
+	Name string
+	// Roles are the roles granted to the peer.
+	Roles []string
+	// Claims are additional attributes provided by the authenticator.
+	Claims map[string]any
+}
+
+// HasRole returns true if the principal has the given role.
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Caller describes the node which issued a call to a source.
+type Caller struct {
+	// Node is the remote node which received the message.
+	Node *Node
+	// Metadata is a copy of the connection metadata of the node.
+	Metadata map[string]string
+	// Principal is the authenticated peer, nil if not authenticated.
+	Principal *Principal
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx carrying the caller.
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller stored in ctx.
+func CallerFromContext(ctx context.Context) (*Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(*Caller)
+	return caller, ok && caller != nil
+}
+
+// NodeFromContext returns the calling node stored in ctx or nil.
+func NodeFromContext(ctx context.Context) *Node {
+	caller, ok := CallerFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return caller.Node
+}
@@ -0,0 +1,33 @@
+package remote
+
+import "github.com/apigear-io/objectlink-core-go/olink/core"
+
+// encodedMessage caches the encoded data of a message per message format,
+// so a notification is encoded only once for all linked nodes.
+// It is not safe for concurrent use.
+type encodedMessage struct {
+	msg  core.Message
+	data map[core.MessageFormat][]byte
+}
+
+// newEncodedMessage creates an encoded message cache for the message
+func newEncodedMessage(msg core.Message) *encodedMessage {
+	return &encodedMessage{
+		msg:  msg,
+		data: make(map[core.MessageFormat][]byte),
+	}
+}
+
+// encode returns the data for the format of the converter,
+// the message is encoded on first use of the format
+func (e *encodedMessage) encode(conv core.MessageConverter) ([]byte, error) {
+	if data, ok := e.data[conv.Format]; ok {
+		return data, nil
+	}
+	data, err := conv.ToData(e.msg)
+	if err != nil {
+		return nil, err
+	}
+	e.data[conv.Format] = data
+	return data, nil
+}
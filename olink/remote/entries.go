@@ -1,19 +1,25 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/apigear-io/objectlink-core-go/log"
 )
 
-type SourceFactory func(objectId string) IObjectSource
+// errSourceChanged is returned when linking to a source which was removed
+var errSourceChanged = errors.New("source changed")
 
 // remoteEntries is a map of object id to sourceToNodeEntry
 type remoteEntries struct {
 	sync.RWMutex
-	entries map[string]*sourceToNodeEntry
-	factory SourceFactory
+	entries   map[string]*sourceToNodeEntry
+	factories factoryRoutes
+	// created is called with a factory created source before it is used
+	created func(source IObjectSource)
 }
 
 // newRemoteEntries creates a new remoteEntries
@@ -23,11 +29,18 @@ func newRemoteEntries() *remoteEntries {
 	}
 }
 
-// setFactory sets the source factory
+// setFactory sets the fallback source factory
 func (r *remoteEntries) setFactory(factory SourceFactory) {
 	r.Lock()
 	defer r.Unlock()
-	r.factory = factory
+	r.factories.fallback = factory
+}
+
+// addFactory adds a source factory for the object ids matching the pattern
+func (r *remoteEntries) addFactory(pattern ObjectPattern, priority int, factory SourceFactory) {
+	r.Lock()
+	defer r.Unlock()
+	r.factories.add(pattern, priority, factory)
 }
 
 // hasEntry returns true if the entry exists
@@ -38,11 +51,40 @@ func (r *remoteEntries) hasEntry(objectId string) bool {
 	return ok
 }
 
+// findPrefix returns the id of an entry which is the prefix or starts with "prefix."
+func (r *remoteEntries) findPrefix(prefix string) (string, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	for objectId := range r.entries {
+		if objectId == prefix || strings.HasPrefix(objectId, prefix+".") {
+			return objectId, true
+		}
+	}
+	return "", false
+}
+
 // removeEntry removes the entry
-func (r *remoteEntries) removeEntry(objectId string) {
+// returns the removed entry or nil
+func (r *remoteEntries) removeEntry(objectId string) *sourceToNodeEntry {
 	r.Lock()
 	defer r.Unlock()
+	e, ok := r.entries[objectId]
+	if !ok {
+		return nil
+	}
+	e.stopIdleTimer()
 	delete(r.entries, objectId)
+	return e
+}
+
+// replaceSource sets the source of the entry, which is created if needed
+// returns the previous source, if it was factory created and the linked nodes
+func (r *remoteEntries) replaceSource(source IObjectSource) (IObjectSource, bool, []*Node) {
+	log.Info().Str("source", source.ObjectId()).Msg("registry: replace")
+	e := r.getEntry(source.ObjectId())
+	old, fromFactory := e.swapSource(source.ObjectId(), source)
+	e.stopIdleTimer()
+	return old, fromFactory, e.getNodes()
 }
 
 // addSource adds the source to the entry
@@ -63,53 +105,166 @@ func (r *remoteEntries) addSource(source IObjectSource) error {
 
 // getSource returns the source
 // if the source does not exist, it is created using a factory
-func (r *remoteEntries) getSource(objectId string) IObjectSource {
-	e := r.getEntry(objectId)
-	if e == nil {
-		log.Error().Msgf("registry: entry %s not found", objectId)
-		return nil
-	}
-	if e.hasSource() {
-		return e.getSource()
+// returns true if the source was created by this call
+func (r *remoteEntries) getSource(objectId string) (IObjectSource, bool) {
+	r.RLock()
+	e, ok := r.entries[objectId]
+	factory := r.factories.lookup(objectId)
+	r.RUnlock()
+	if ok && e.hasSource() {
+		return e.getSource(), false
 	}
-	r.Lock()
-	factory := r.factory
-	r.Unlock()
 	if factory == nil {
 		log.Error().Msgf("registry: no source and no factory found for %s", objectId)
+		return nil, false
+	}
+	source := factory(objectId)
+	if source == nil {
+		log.Warn().Msgf("registry: factory refused to create %s", objectId)
+		return nil, false
+	}
+	if r.created != nil {
+		r.created(source)
+	}
+	// the factory runs without the lock, a concurrent call may have stored a source
+	stored := r.getEntry(objectId).setFactorySource(source)
+	if stored != source {
+		if d, ok := source.(IDisposableSource); ok {
+			d.Dispose()
+		}
+		return stored, false
+	}
+	return source, true
+}
+
+// updateActive informs the source of the object if it became active or inactive
+func (r *remoteEntries) updateActive(objectId string) {
+	r.RLock()
+	e, ok := r.entries[objectId]
+	r.RUnlock()
+	if ok {
+		e.updateActive(objectId)
+	}
+}
+
+// lookupSource returns the source without using the factory
+func (r *remoteEntries) lookupSource(objectId string) IObjectSource {
+	r.RLock()
+	e, ok := r.entries[objectId]
+	r.RUnlock()
+	if !ok {
 		return nil
 	}
-	e.setSource(r.factory(objectId))
 	return e.getSource()
 }
 
 // addNode adds the node to the entry
-func (r *remoteEntries) addNode(objectId string, node *Node) error {
-	e := r.getEntry(objectId)
-	return e.addNode(node)
+// returns true if the node is the first linked node
+func (r *remoteEntries) addNode(objectId string, node *Node) (bool, error) {
+	return r.linkNode(objectId, node, nil)
+}
+
+// linkNode adds the node to the entry if the entry still holds the source,
+// which may have been evicted or replaced since it was looked up.
+// A nil source links the node to any entry.
+// returns true if the node is the first linked node
+func (r *remoteEntries) linkNode(objectId string, node *Node, source IObjectSource) (bool, error) {
+	// hold the lock so the entry can not be evicted concurrently
+	r.Lock()
+	defer r.Unlock()
+	e, ok := r.entries[objectId]
+	if source != nil && (!ok || e.getSource() != source) {
+		return false, errSourceChanged
+	}
+	if !ok {
+		e = &sourceToNodeEntry{}
+		r.entries[objectId] = e
+	}
+	first, err := e.addNode(node)
+	if first {
+		e.stopIdleTimer()
+	}
+	return first, err
 }
 
 // removeNode removes the node from the entry
-func (r *remoteEntries) removeNode(objectId string, node *Node) {
-	e := r.getEntry(objectId)
-	e.removeNode(node)
+// returns if the node was removed and if it was the last linked node
+func (r *remoteEntries) removeNode(objectId string, node *Node) (bool, bool) {
+	r.RLock()
+	e, ok := r.entries[objectId]
+	r.RUnlock()
+	if !ok {
+		return false, false
+	}
+	removed, last := e.removeNode(node)
+	if last {
+		r.prune(objectId)
+	}
+	return removed, last
+}
+
+// unlinkResult describes a node removed from an entry
+type unlinkResult struct {
+	objectId string
+	last     bool
 }
 
 // purgeNode removes the node from all entries
-func (r *remoteEntries) purgeNode(node *Node) {
+// returns the entries the node was removed from
+func (r *remoteEntries) purgeNode(node *Node) []unlinkResult {
 	r.RLock()
-	defer r.RUnlock()
-	for _, e := range r.entries {
-		e.removeNode(node)
+	var results []unlinkResult
+	for objectId, e := range r.entries {
+		removed, last := e.removeNode(node)
+		if removed {
+			results = append(results, unlinkResult{objectId: objectId, last: last})
+		}
+	}
+	r.RUnlock()
+	for _, res := range results {
+		if res.last {
+			r.prune(res.objectId)
+		}
+	}
+	return results
+}
+
+// prune removes the entry if it has neither a source nor nodes
+func (r *remoteEntries) prune(objectId string) {
+	r.Lock()
+	defer r.Unlock()
+	e, ok := r.entries[objectId]
+	if ok && e.isEmpty() {
+		e.stopIdleTimer()
+		delete(r.entries, objectId)
 	}
 }
 
 // getNodes returns the list of nodes
 func (r *remoteEntries) getNodes(objectId string) []*Node {
-	e := r.getEntry(objectId)
+	r.RLock()
+	e, ok := r.entries[objectId]
+	r.RUnlock()
+	if !ok {
+		return nil
+	}
 	return e.getNodes()
 }
 
+// linkedNodes returns the nodes of all entries with linked nodes
+func (r *remoteEntries) linkedNodes() map[string][]*Node {
+	r.RLock()
+	defer r.RUnlock()
+	result := make(map[string][]*Node)
+	for objectId, e := range r.entries {
+		nodes := e.getNodes()
+		if len(nodes) > 0 {
+			result[objectId] = nodes
+		}
+	}
+	return result
+}
+
 // getEntry returns the entry
 // if the entry does not exist, it is created
 func (r *remoteEntries) getEntry(objectId string) *sourceToNodeEntry {
@@ -122,3 +277,28 @@ func (r *remoteEntries) getEntry(objectId string) *sourceToNodeEntry {
 	}
 	return r.entries[objectId]
 }
+
+// startIdleTimer calls fn after the ttl unless a node links to the object
+func (r *remoteEntries) startIdleTimer(objectId string, ttl time.Duration, fn func()) {
+	r.RLock()
+	defer r.RUnlock()
+	e, ok := r.entries[objectId]
+	if !ok {
+		return
+	}
+	e.startIdleTimer(ttl, fn)
+}
+
+// evict removes the entry if the source is factory created and has no nodes
+// returns the evicted source or nil
+func (r *remoteEntries) evict(objectId string) IObjectSource {
+	r.Lock()
+	defer r.Unlock()
+	e, ok := r.entries[objectId]
+	if !ok || !e.isEvictable() {
+		return nil
+	}
+	e.stopIdleTimer()
+	delete(r.entries, objectId)
+	return e.getSource()
+}
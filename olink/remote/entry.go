@@ -3,6 +3,7 @@ package remote
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 // sourceToNodeEntry links an object source to a list of nodes
@@ -10,32 +11,42 @@ type sourceToNodeEntry struct {
 	sync.RWMutex
 	source IObjectSource
 	nodes  []*Node
+	// fromFactory is true if the source was created by the source factory
+	fromFactory bool
+	idleTimer   *time.Timer
+	// transition serializes the calls of ActiveChanged
+	transition sync.Mutex
+	// active is the state last reported to the source, guarded by transition
+	active bool
 }
 
 // addNode adds the node to the entry
+// returns true if the node is the first linked node
 // returns an error if the node already exists
-func (e *sourceToNodeEntry) addNode(node *Node) error {
+func (e *sourceToNodeEntry) addNode(node *Node) (bool, error) {
 	e.Lock()
 	defer e.Unlock()
 	for _, n := range e.nodes {
 		if n == node {
-			return fmt.Errorf("node already exists")
+			return false, fmt.Errorf("node already exists")
 		}
 	}
 	e.nodes = append(e.nodes, node)
-	return nil
+	return len(e.nodes) == 1, nil
 }
 
 // removeNode removes the node from the entry
-func (e *sourceToNodeEntry) removeNode(node *Node) {
+// returns if the node was removed and if it was the last linked node
+func (e *sourceToNodeEntry) removeNode(node *Node) (bool, bool) {
 	e.Lock()
 	defer e.Unlock()
 	for i, n := range e.nodes {
 		if n == node {
 			e.nodes = append(e.nodes[:i], e.nodes[i+1:]...)
-			return
+			return true, len(e.nodes) == 0
 		}
 	}
+	return false, false
 }
 
 // hasNode returns true if the node is linked to the source
@@ -79,3 +90,110 @@ func (e *sourceToNodeEntry) hasSource() bool {
 	defer e.RUnlock()
 	return e.source != nil
 }
+
+// setFactorySource sets the source created by a factory
+// if the entry has already a source, the existing source is returned
+func (e *sourceToNodeEntry) setFactorySource(source IObjectSource) IObjectSource {
+	e.Lock()
+	defer e.Unlock()
+	if e.source != nil {
+		return e.source
+	}
+	e.source = source
+	e.fromFactory = true
+	return source
+}
+
+// replaceSource sets the source, which is not factory created,
+// returns the previous source and if it was factory created
+func (e *sourceToNodeEntry) replaceSource(source IObjectSource) (IObjectSource, bool) {
+	e.Lock()
+	defer e.Unlock()
+	old, fromFactory := e.source, e.fromFactory
+	e.source = source
+	e.fromFactory = false
+	return old, fromFactory
+}
+
+// updateActive informs the source if it became active or inactive.
+// The node count is checked while holding the transition lock, so concurrent
+// links and unlinks can not report the states out of order.
+func (e *sourceToNodeEntry) updateActive(objectId string) {
+	e.transition.Lock()
+	defer e.transition.Unlock()
+	e.RLock()
+	source := e.source
+	active := source != nil && len(e.nodes) > 0
+	e.RUnlock()
+	e.setActive(objectId, source, active)
+}
+
+// setActive reports the state to the source if it changed,
+// the caller must hold the transition lock
+func (e *sourceToNodeEntry) setActive(objectId string, source IObjectSource, active bool) {
+	if e.active == active {
+		return
+	}
+	e.active = active
+	if as, ok := source.(IActiveSource); ok {
+		as.ActiveChanged(objectId, active)
+	}
+}
+
+// swapSource replaces the source like replaceSource. If nodes are linked,
+// the previous source becomes inactive and the new source active.
+func (e *sourceToNodeEntry) swapSource(objectId string, source IObjectSource) (IObjectSource, bool) {
+	e.transition.Lock()
+	defer e.transition.Unlock()
+	old, fromFactory := e.replaceSource(source)
+	if old != source {
+		e.setActive(objectId, old, false)
+	}
+	e.RLock()
+	active := len(e.nodes) > 0
+	e.RUnlock()
+	e.setActive(objectId, source, active)
+	return old, fromFactory
+}
+
+// deactivate reports the removed source as inactive if it was active
+func (e *sourceToNodeEntry) deactivate(objectId string) {
+	e.transition.Lock()
+	defer e.transition.Unlock()
+	e.setActive(objectId, e.getSource(), false)
+}
+
+// isEmpty returns true if the entry has neither a source nor nodes
+func (e *sourceToNodeEntry) isEmpty() bool {
+	e.RLock()
+	defer e.RUnlock()
+	return e.source == nil && len(e.nodes) == 0
+}
+
+// isEvictable returns true if the source was created by a factory
+// and no node is linked
+func (e *sourceToNodeEntry) isEvictable() bool {
+	e.RLock()
+	defer e.RUnlock()
+	return e.fromFactory && e.source != nil && len(e.nodes) == 0
+}
+
+// startIdleTimer calls fn after the ttl unless the timer is stopped
+func (e *sourceToNodeEntry) startIdleTimer(ttl time.Duration, fn func()) {
+	e.Lock()
+	defer e.Unlock()
+	if e.idleTimer != nil {
+		e.idleTimer.Stop()
+	}
+	e.idleTimer = time.AfterFunc(ttl, fn)
+}
+
+// stopIdleTimer stops a running idle timer
+func (e *sourceToNodeEntry) stopIdleTimer() {
+	e.Lock()
+	defer e.Unlock()
+	if e.idleTimer != nil {
+		e.idleTimer.Stop()
+		e.idleTimer = nil
+	}
+}
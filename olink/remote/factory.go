@@ -0,0 +1,83 @@
+package remote
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SourceFactory creates the source of an object on demand.
+// Returning nil refuses the creation of the object.
+type SourceFactory func(objectId string) IObjectSource
+
+// ObjectPattern matches object ids for source factories.
+type ObjectPattern interface {
+	Match(objectId string) bool
+}
+
+// PrefixPattern matches object ids starting with the prefix,
+// e.g. "demo." matches all objects of the demo module.
+type PrefixPattern string
+
+// Match returns true if the object id starts with the prefix.
+func (p PrefixPattern) Match(objectId string) bool {
+	return strings.HasPrefix(objectId, string(p))
+}
+
+// GlobPattern matches object ids using path.Match syntax, e.g. "demo.*".
+type GlobPattern string
+
+// Match returns true if the object id matches the glob.
+func (p GlobPattern) Match(objectId string) bool {
+	ok, err := path.Match(string(p), objectId)
+	return err == nil && ok
+}
+
+// RegexpPattern matches object ids using a regular expression.
+type RegexpPattern struct {
+	*regexp.Regexp
+}
+
+// MustRegexpPattern compiles the expression and panics if it is invalid.
+func MustRegexpPattern(expr string) RegexpPattern {
+	return RegexpPattern{regexp.MustCompile(expr)}
+}
+
+// Match returns true if the regular expression matches the object id.
+func (p RegexpPattern) Match(objectId string) bool {
+	return p.MatchString(objectId)
+}
+
+// routedFactory is a source factory registered for a pattern
+type routedFactory struct {
+	pattern  ObjectPattern
+	priority int
+	factory  SourceFactory
+}
+
+// factoryRoutes selects the source factory of an object id
+type factoryRoutes struct {
+	routes   []routedFactory
+	fallback SourceFactory
+}
+
+// add adds the factory, sorted by descending priority.
+// Factories with the same priority keep the order they were added.
+func (f *factoryRoutes) add(pattern ObjectPattern, priority int, factory SourceFactory) {
+	f.routes = append(f.routes, routedFactory{pattern: pattern, priority: priority, factory: factory})
+	sort.SliceStable(f.routes, func(i, j int) bool {
+		return f.routes[i].priority > f.routes[j].priority
+	})
+}
+
+// lookup returns the factory with the highest priority matching the object id
+// or the fallback factory
+func (f *factoryRoutes) lookup(objectId string) SourceFactory {
+	for _, r := range f.routes {
+		if r.pattern.Match(objectId) {
+			return r.factory
+		}
+	}
+	return f.fallback
+}
@@ -0,0 +1,57 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectPatterns(t *testing.T) {
+	assert.True(t, PrefixPattern("demo.").Match("demo.Counter"))
+	assert.False(t, PrefixPattern("demo.").Match("demox.Counter"))
+	assert.True(t, GlobPattern("demo.*").Match("demo.Counter"))
+	assert.False(t, GlobPattern("demo.*").Match("other.Counter"))
+	assert.True(t, MustRegexpPattern(`^demo\.Counter\d+$`).Match("demo.Counter42"))
+	assert.False(t, MustRegexpPattern(`^demo\.Counter\d+$`).Match("demo.Counter"))
+}
+
+func TestAddSourceFactory(t *testing.T) {
+	r := NewRegistry()
+	created := map[string]string{}
+	factory := func(name string) SourceFactory {
+		return func(objectId string) IObjectSource {
+			created[objectId] = name
+			return NewMockSource(objectId)
+		}
+	}
+	r.SetSourceFactory(factory("fallback"))
+	r.AddSourceFactory(PrefixPattern("demo."), 0, factory("demo"))
+	r.AddSourceFactory(GlobPattern("demo.Counter*"), 10, factory("counter"))
+	r.AddSourceFactory(PrefixPattern("secret."), 5, func(objectId string) IObjectSource {
+		return nil
+	})
+	assert.NotNil(t, r.GetObjectSource("demo.Counter1"))
+	assert.NotNil(t, r.GetObjectSource("demo.Storage"))
+	assert.NotNil(t, r.GetObjectSource("other.Storage"))
+	assert.Nil(t, r.GetObjectSource("secret.Keys"))
+	assert.Equal(t, map[string]string{
+		"demo.Counter1": "counter",
+		"demo.Storage":  "demo",
+		"other.Storage": "fallback",
+	}, created)
+}
+
+func TestAddSourceFactorySamePriority(t *testing.T) {
+	r := NewRegistry()
+	var used string
+	r.AddSourceFactory(PrefixPattern("demo."), 1, func(objectId string) IObjectSource {
+		used = "first"
+		return NewMockSource(objectId)
+	})
+	r.AddSourceFactory(PrefixPattern("demo."), 1, func(objectId string) IObjectSource {
+		used = "second"
+		return NewMockSource(objectId)
+	})
+	r.GetObjectSource("demo.Counter")
+	assert.Equal(t, "first", used)
+}
@@ -0,0 +1,108 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/log"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// OpKind is the kind of a client operation handled by a node.
+type OpKind int
+
+const (
+	OpLink OpKind = iota
+	OpUnlink
+	OpSetProperty
+	OpInvoke
+	OpSignal
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpLink:
+		return "link"
+	case OpUnlink:
+		return "unlink"
+	case OpSetProperty:
+		return "set"
+	case OpInvoke:
+		return "invoke"
+	case OpSignal:
+		return "signal"
+	}
+	return "unknown"
+}
+
+// MsgType returns the message type which requested the operation.
+func (k OpKind) MsgType() core.MsgType {
+	switch k {
+	case OpLink:
+		return core.MsgLink
+	case OpUnlink:
+		return core.MsgUnlink
+	case OpSetProperty:
+		return core.MsgSetProperty
+	case OpInvoke:
+		return core.MsgInvoke
+	case OpSignal:
+		return core.MsgSignal
+	}
+	return core.MsgUnknown
+}
+
+// Operation describes a client operation passed through the interceptor chain.
+// Interceptors may modify the operation before calling the next handler.
+type Operation struct {
+	Kind OpKind
+	// Node is the node which received the operation.
+	Node     *Node
+	ObjectId string
+	// Member is the property, method or signal name, empty for link and unlink.
+	Member string
+	// RequestId is the request id of an invoke.
+	RequestId int64
+	// Args are the arguments of an invoke or signal.
+	Args core.Args
+	// Value is the value of a property set.
+	Value core.Any
+}
+
+// SymbolId returns the symbol id of the member or the object id.
+func (op *Operation) SymbolId() string {
+	if op.Member == "" {
+		return op.ObjectId
+	}
+	return core.MakeSymbolId(op.ObjectId, op.Member)
+}
+
+// Handler executes an operation and returns the result of an invoke.
+type Handler func(ctx context.Context, op *Operation) (core.Any, error)
+
+// Interceptor wraps the handling of an operation. It may inspect or modify
+// the operation, reject it by returning an error without calling next,
+// or observe the result of next. A rejection is reported to the client
+// as an error message.
+type Interceptor func(ctx context.Context, op *Operation, next Handler) (core.Any, error)
+
+// chainInterceptors returns a handler calling the interceptors in order and then the final handler
+func chainInterceptors(interceptors []Interceptor, final Handler) Handler {
+	h := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := h
+		h = func(ctx context.Context, op *Operation) (core.Any, error) {
+			return interceptor(ctx, op, next)
+		}
+	}
+	return h
+}
+
+// LogInterceptor logs each operation with its duration.
+func LogInterceptor(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+	start := time.Now()
+	result, err := next(ctx, op)
+	log.Debug().Msgf("node %s: %s %s took %s (err=%v)", op.Node.Id(), op.Kind, op.SymbolId(), time.Since(start), err)
+	return result, err
+}
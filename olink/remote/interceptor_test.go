@@ -0,0 +1,111 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptorOrder(t *testing.T) {
+	r := NewRegistry()
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+			calls = append(calls, name+":"+op.Kind.String())
+			return next(ctx, op)
+		}
+	}
+	r.Use(trace("a"), trace("b"))
+	n := NewNode(r)
+	n.SetOutput(NewMockWriteCloser())
+	r.AddObjectSource(NewMockSource("demo.Counter"))
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	n.handleMessage(core.MakeInvokeMessage(1, "demo.Counter/increment", core.Args{}))
+	n.handleMessage(core.MakeUnlinkMessage("demo.Counter"))
+	assert.Equal(t, []string{"a:link", "b:link", "a:invoke", "b:invoke", "a:unlink", "b:unlink"}, calls)
+}
+
+func TestInterceptorRejectInvoke(t *testing.T) {
+	r := NewRegistry()
+	r.Use(func(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+		if op.Kind == OpInvoke && op.Member == "reset" {
+			return nil, errors.New("denied")
+		}
+		return next(ctx, op)
+	})
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	s := NewMockSource("demo.Counter")
+	called := false
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		called = true
+		return nil, nil
+	}
+	r.AddObjectSource(s)
+	n.handleMessage(core.MakeInvokeMessage(3, "demo.Counter/reset", core.Args{}))
+	assert.False(t, called)
+	assert.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	msgType, requestId, errMsg := msg.AsError()
+	assert.Equal(t, core.MsgInvoke, msgType)
+	assert.Equal(t, int64(3), requestId)
+	assert.Contains(t, errMsg, "denied")
+}
+
+func TestInterceptorRejectSetProperty(t *testing.T) {
+	r := NewRegistry()
+	r.Use(func(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+		if op.Kind == OpSetProperty {
+			return nil, errors.New("read only")
+		}
+		return next(ctx, op)
+	})
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	s := NewMockSource("demo.Counter")
+	called := false
+	s.SetPropertyHandler = func(propertyId string, value core.Any) error {
+		called = true
+		return nil
+	}
+	r.AddObjectSource(s)
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 1))
+	assert.False(t, called)
+	assert.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgError, msg.Type())
+	msgType, _, _ := msg.AsError()
+	assert.Equal(t, core.MsgSetProperty, msgType)
+}
+
+func TestInterceptorModifyArgs(t *testing.T) {
+	r := NewRegistry()
+	r.Use(func(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+		if op.Kind == OpInvoke {
+			op.Args = append(op.Args, "extra")
+		}
+		result, err := next(ctx, op)
+		return []any{result, "wrapped"}, err
+	})
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		return len(args), nil
+	}
+	r.AddObjectSource(s)
+	n.handleMessage(core.MakeInvokeMessage(1, "demo.Counter/add", core.Args{1}))
+	assert.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	_, _, value := msg.AsInvokeReply()
+	assert.Equal(t, []any{float64(2), "wrapped"}, value)
+}
@@ -0,0 +1,298 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// Journal entry kinds.
+const (
+	JournalSet    = "set"
+	JournalChange = "change"
+	JournalInvoke = "invoke"
+	JournalSignal = "signal"
+)
+
+// JournalEntry records an operation on an object.
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	// Kind is one of the Journal kind constants.
+	Kind string `json:"kind"`
+	// NodeId is the id of the node which sent the operation, empty for source notifications.
+	NodeId   string `json:"node,omitempty"`
+	ObjectId string `json:"object"`
+	// Member is the property, method or signal name, empty for changes.
+	Member string    `json:"member,omitempty"`
+	Args   core.Args `json:"args,omitempty"`
+	// Value is the value of a set, the changed properties or the result of an invoke.
+	Value core.Any `json:"value,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// JournalOptions configures the rotation of the journal files.
+type JournalOptions struct {
+	// MaxSize is the size of a journal file in bytes which triggers a rotation.
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept besides the current file.
+	MaxFiles int
+}
+
+// DefaultJournalOptions returns the default journal options.
+func DefaultJournalOptions() JournalOptions {
+	return JournalOptions{
+		MaxSize:  10 << 20,
+		MaxFiles: 5,
+	}
+}
+
+// journalFile is the name of the current journal file
+const journalFile = "journal.jsonl"
+
+// Journal appends entries as JSON lines to files in a directory.
+// The current file is journal.jsonl, rotated files are named
+// journal.1.jsonl (newest) up to journal.<MaxFiles>.jsonl (oldest).
+// The journal is an audit log and not crash durable: Append does not sync
+// the file and Close does not flush, entries written shortly before a crash
+// of the system may be lost.
+type Journal struct {
+	mu   sync.Mutex
+	dir  string
+	opts JournalOptions
+	file *os.File
+	size int64
+}
+
+// NewJournal opens the journal in the directory, which is created if needed.
+func NewJournal(dir string, opts JournalOptions) (*Journal, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultJournalOptions().MaxSize
+	}
+	if opts.MaxFiles < 0 {
+		opts.MaxFiles = 0
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	j := &Journal{dir: dir, opts: opts}
+	err = j.open()
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Dir returns the directory of the journal.
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// Append writes the entry to the journal, the time is set if it is zero.
+func (j *Journal) Append(e JournalEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	data = append(data, '\n')
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("journal: closed")
+	}
+	if j.size > 0 && j.size+int64(len(data)) > j.opts.MaxSize {
+		err = j.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := j.file.Write(data)
+	j.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+// Query reads the entries of the journal matching the query.
+// The files are opened while holding the lock and read after releasing it,
+// so a query does not block appends and a rotation does not move the files away.
+func (j *Journal) Query(q JournalQuery) ([]JournalEntry, error) {
+	j.mu.Lock()
+	files, err := openJournalFiles(j.dir)
+	j.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return readJournalFiles(files, q)
+}
+
+// Close closes the current journal file without syncing it.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// open opens the current file for appending
+func (j *Journal) open() error {
+	f, err := os.OpenFile(filepath.Join(j.dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("journal: %w", err)
+	}
+	j.file = f
+	j.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files and starts a new current file,
+// the caller must hold the lock
+func (j *Journal) rotate() error {
+	err := j.file.Close()
+	j.file = nil
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if j.opts.MaxFiles == 0 {
+		os.Remove(filepath.Join(j.dir, journalFile))
+		return j.open()
+	}
+	os.Remove(rotatedJournalFile(j.dir, j.opts.MaxFiles))
+	for i := j.opts.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(rotatedJournalFile(j.dir, i), rotatedJournalFile(j.dir, i+1))
+	}
+	err = os.Rename(filepath.Join(j.dir, journalFile), rotatedJournalFile(j.dir, 1))
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return j.open()
+}
+
+// rotatedJournalFile returns the path of the rotated file with the index
+func rotatedJournalFile(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("journal.%d.jsonl", index))
+}
+
+// JournalQuery selects journal entries, zero values match all entries.
+type JournalQuery struct {
+	ObjectId string
+	Kinds    []string
+	// Since and Until limit the time range, both are inclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// matches returns true if the entry matches the query
+func (q JournalQuery) matches(e JournalEntry) bool {
+	if q.ObjectId != "" && e.ObjectId != q.ObjectId {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if len(q.Kinds) == 0 {
+		return true
+	}
+	for _, k := range q.Kinds {
+		if k == e.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadJournal reads the entries of the journal in the directory matching the query,
+// ordered by time. Lines which can not be decoded are skipped.
+func ReadJournal(dir string, q JournalQuery) ([]JournalEntry, error) {
+	files, err := openJournalFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return readJournalFiles(files, q)
+}
+
+// openJournalFiles opens the journal files in the directory, the oldest rotated file first
+func openJournalFiles(dir string) ([]*os.File, error) {
+	var files []*os.File
+	for i := 1; ; i++ {
+		f, err := os.Open(rotatedJournalFile(dir, i))
+		if err != nil {
+			break
+		}
+		files = append([]*os.File{f}, files...)
+	}
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		closeJournalFiles(files)
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	return append(files, f), nil
+}
+
+// closeJournalFiles closes the files
+func closeJournalFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// readJournalFiles reads the matching entries of the files and closes them
+func readJournalFiles(files []*os.File, q JournalQuery) ([]JournalEntry, error) {
+	defer closeJournalFiles(files)
+	var entries []JournalEntry
+	for _, f := range files {
+		err := readJournalFile(f, q, &entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(entries, func(i, k int) bool {
+		return entries[i].Time.Before(entries[k].Time)
+	})
+	return entries, nil
+}
+
+// readJournalFile appends the matching entries of the file
+func readJournalFile(f *os.File, q JournalQuery, entries *[]JournalEntry) error {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var e JournalEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if q.matches(e) {
+			*entries = append(*entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("journal %s: %w", f.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalRotation(t *testing.T) {
+	dir := t.TempDir()
+	j, err := NewJournal(dir, JournalOptions{MaxSize: 200, MaxFiles: 2})
+	assert.Nil(t, err)
+	defer j.Close()
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		err = j.Append(JournalEntry{Time: start.Add(time.Duration(i) * time.Second), Kind: JournalSet, ObjectId: "demo.Counter", Member: "count", Value: i})
+		assert.Nil(t, err)
+	}
+	_, err = os.Stat(rotatedJournalFile(dir, 2))
+	assert.Nil(t, err)
+	_, err = os.Stat(rotatedJournalFile(dir, 3))
+	assert.True(t, os.IsNotExist(err))
+	entries, err := j.Query(JournalQuery{})
+	assert.Nil(t, err)
+	assert.Less(t, len(entries), 20)
+	// the newest entries are kept in order
+	last := entries[len(entries)-1]
+	assert.Equal(t, float64(19), last.Value)
+	for i := 1; i < len(entries); i++ {
+		assert.False(t, entries[i].Time.Before(entries[i-1].Time))
+	}
+}
+
+func TestJournalQuery(t *testing.T) {
+	dir := t.TempDir()
+	j, err := NewJournal(dir, DefaultJournalOptions())
+	assert.Nil(t, err)
+	start := time.Now()
+	j.Append(JournalEntry{Time: start, Kind: JournalSet, ObjectId: "demo.Counter"})
+	j.Append(JournalEntry{Time: start.Add(time.Second), Kind: JournalInvoke, ObjectId: "demo.Counter"})
+	j.Append(JournalEntry{Time: start.Add(2 * time.Second), Kind: JournalSet, ObjectId: "demo.Other"})
+	assert.Nil(t, j.Close())
+	entries, err := ReadJournal(dir, JournalQuery{ObjectId: "demo.Counter"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	entries, err = ReadJournal(dir, JournalQuery{Since: start.Add(time.Second)})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	entries, err = ReadJournal(dir, JournalQuery{Until: start.Add(time.Second), Kinds: []string{JournalSet}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Error(t, j.Append(JournalEntry{Kind: JournalSet}))
+}
+
+func TestRegistryJournal(t *testing.T) {
+	j, err := NewJournal(t.TempDir(), DefaultJournalOptions())
+	assert.Nil(t, err)
+	defer j.Close()
+	r := NewRegistry()
+	r.SetJournal(j)
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		if methodId == "fail" {
+			return nil, errors.New("failed")
+		}
+		return 42, nil
+	}
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	n.SetOutput(NewMockWriteCloser())
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 1))
+	n.handleMessage(core.MakeInvokeMessage(1, "demo.Counter/increment", core.Args{1}))
+	n.handleMessage(core.MakeInvokeMessage(2, "demo.Counter/fail", core.Args{}))
+	n.handleMessage(core.MakeSignalMessage("demo.Counter/ping", core.Args{}))
+	r.NotifyPropertyChange("demo.Counter", core.KWArgs{"count": 2})
+	entries, err := j.Query(JournalQuery{ObjectId: "demo.Counter"})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(entries))
+	kinds := []string{}
+	for _, e := range entries {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal(t, []string{JournalSet, JournalInvoke, JournalInvoke, JournalSignal, JournalChange}, kinds)
+	assert.Equal(t, n.Id(), entries[0].NodeId)
+	assert.Equal(t, float64(42), entries[1].Value)
+	assert.Equal(t, "failed", entries[2].Error)
+	assert.Equal(t, n.Id(), entries[3].NodeId)
+	assert.Empty(t, entries[4].NodeId)
+}
+
+func TestJournalSetOutcome(t *testing.T) {
+	j, err := NewJournal(t.TempDir(), DefaultJournalOptions())
+	assert.Nil(t, err)
+	defer j.Close()
+	r := NewRegistry()
+	r.SetJournal(j)
+	s := NewMockSource("demo.Counter")
+	s.SetPropertyHandler = func(propertyId string, value core.Any) error {
+		if propertyId == "broken" {
+			return errors.New("failed")
+		}
+		return nil
+	}
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	n.SetOutput(NewMockWriteCloser())
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 1))
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/broken", 2))
+	// each set is recorded once with its outcome
+	entries, err := j.Query(JournalQuery{Kinds: []string{JournalSet}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "count", entries[0].Member)
+	assert.Empty(t, entries[0].Error)
+	assert.Equal(t, "broken", entries[1].Member)
+	assert.Equal(t, "failed", entries[1].Error)
+}
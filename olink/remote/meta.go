@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// Property types used by PropertyMeta.
+const (
+	TypeBool   = "bool"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeString = "string"
+	TypeArray  = "array"
+	TypeObject = "object"
+)
+
+var (
+	int64Type   = reflect.TypeOf(int64(0))
+	float64Type = reflect.TypeOf(float64(0))
+)
+
+// PropertyMeta describes a property of an object.
+type PropertyMeta struct {
+	// Type is one of the Type constants, empty accepts any value.
+	Type string
+	// ReadOnly rejects property sets from clients.
+	ReadOnly bool
+	// Min and Max limit numeric values, nil means no limit.
+	Min *float64
+	Max *float64
+	// Enum lists the allowed values, empty allows all values.
+	Enum []any
+	// Default is sent in the init message if the source has no value.
+	Default any
+}
+
+// Validate checks the value against the type and the constraints.
+func (p PropertyMeta) Validate(value core.Any) error {
+	switch p.Type {
+	case "":
+	case TypeInt, TypeFloat:
+		t := float64Type
+		if p.Type == TypeInt {
+			t = int64Type
+		}
+		if _, err := core.ConvertValue(value, t); err != nil || value == nil {
+			return fmt.Errorf("expected %s value, got %T", p.Type, value)
+		}
+	case TypeBool, TypeString:
+		kind := reflect.Bool
+		if p.Type == TypeString {
+			kind = reflect.String
+		}
+		if value == nil || reflect.TypeOf(value).Kind() != kind {
+			return fmt.Errorf("expected %s value, got %T", p.Type, value)
+		}
+	case TypeArray, TypeObject:
+		kind := reflect.Slice
+		if p.Type == TypeObject {
+			kind = reflect.Map
+		}
+		if value == nil || reflect.TypeOf(value).Kind() != kind {
+			return fmt.Errorf("expected %s value, got %T", p.Type, value)
+		}
+	default:
+		return fmt.Errorf("unknown property type %s", p.Type)
+	}
+	if p.Min != nil || p.Max != nil {
+		v, err := core.ConvertValue(value, float64Type)
+		if err != nil {
+			return fmt.Errorf("expected numeric value, got %T", value)
+		}
+		f := v.Float()
+		if p.Min != nil && f < *p.Min {
+			return fmt.Errorf("value %v is less than %v", value, *p.Min)
+		}
+		if p.Max != nil && f > *p.Max {
+			return fmt.Errorf("value %v is greater than %v", value, *p.Max)
+		}
+	}
+	if len(p.Enum) > 0 && !containsValue(p.Enum, value) {
+		return fmt.Errorf("value %v is not one of %v", value, p.Enum)
+	}
+	return nil
+}
+
+// ObjectMeta describes the properties of an object.
+type ObjectMeta struct {
+	Properties map[string]PropertyMeta
+	// Strict rejects properties which are not described.
+	Strict bool
+}
+
+// ValidateSet checks if a client may set the property to the value.
+func (m *ObjectMeta) ValidateSet(name string, value core.Any) error {
+	p, ok := m.Properties[name]
+	if !ok {
+		if m.Strict {
+			return fmt.Errorf("unknown property %s", name)
+		}
+		return nil
+	}
+	if p.ReadOnly {
+		return fmt.Errorf("property %s is read-only", name)
+	}
+	err := p.Validate(value)
+	if err != nil {
+		return fmt.Errorf("property %s: %w", name, err)
+	}
+	return nil
+}
+
+// ApplyDefaults returns a copy of props with the default values
+// of missing properties added. props is not modified, since it
+// may be the state of the source.
+func (m *ObjectMeta) ApplyDefaults(props core.KWArgs) core.KWArgs {
+	result := make(core.KWArgs, len(props)+len(m.Properties))
+	for name, value := range props {
+		result[name] = value
+	}
+	for name, p := range m.Properties {
+		if p.Default == nil {
+			continue
+		}
+		if _, ok := result[name]; !ok {
+			result[name] = p.Default
+		}
+	}
+	return result
+}
+
+// IMetaSource is an optional interface for sources which describe their properties.
+// Metadata set using Registry.SetObjectMeta takes precedence.
+type IMetaSource interface {
+	ObjectMeta() *ObjectMeta
+}
+
+// containsValue compares numbers by value and other values deeply
+func containsValue(values []any, value core.Any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+		a, errA := core.ConvertValue(v, float64Type)
+		b, errB := core.ConvertValue(value, float64Type)
+		if errA == nil && errB == nil && v != nil && value != nil && a.Float() == b.Float() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/require"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestPropertyMetaValidate(t *testing.T) {
+	t.Parallel()
+	p := PropertyMeta{Type: TypeInt, Min: float(0), Max: float(10)}
+	require.NoError(t, p.Validate(float64(5)))
+	require.Error(t, p.Validate(5.5))
+	require.Error(t, p.Validate(float64(11)))
+	require.Error(t, p.Validate(float64(-1)))
+	require.Error(t, p.Validate("5"))
+	require.Error(t, p.Validate(nil))
+	p = PropertyMeta{Type: TypeString, Enum: []any{"on", "off"}}
+	require.NoError(t, p.Validate("on"))
+	require.Error(t, p.Validate("dim"))
+	require.Error(t, p.Validate(true))
+	p = PropertyMeta{Enum: []any{1, 2}}
+	require.NoError(t, p.Validate(float64(2)))
+	require.Error(t, p.Validate(float64(3)))
+	p = PropertyMeta{Type: TypeArray}
+	require.NoError(t, p.Validate([]any{1}))
+	require.Error(t, p.Validate(map[string]any{}))
+}
+
+func TestObjectMetaValidateSet(t *testing.T) {
+	t.Parallel()
+	m := &ObjectMeta{
+		Properties: map[string]PropertyMeta{
+			"count": {Type: TypeInt, Default: 0},
+			"name":  {Type: TypeString, ReadOnly: true, Default: "counter"},
+		},
+	}
+	require.NoError(t, m.ValidateSet("count", float64(1)))
+	require.ErrorContains(t, m.ValidateSet("name", "other"), "read-only")
+	require.NoError(t, m.ValidateSet("other", 1))
+	m.Strict = true
+	require.Error(t, m.ValidateSet("other", 1))
+	state := core.KWArgs{"count": 5}
+	props := m.ApplyDefaults(state)
+	require.Equal(t, core.KWArgs{"count": 5, "name": "counter"}, props)
+	// the defaults are not written into the given properties
+	require.Equal(t, core.KWArgs{"count": 5}, state)
+}
+
+func TestNodeEnforcesObjectMeta(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	s := NewMockSource("demo.Counter")
+	var sets []string
+	s.SetPropertyHandler = func(propertyId string, value core.Any) error {
+		sets = append(sets, propertyId)
+		return nil
+	}
+	r.AddObjectSource(s)
+	r.SetObjectMeta(s.ObjectId(), &ObjectMeta{
+		Properties: map[string]PropertyMeta{
+			"name": {Type: TypeString, ReadOnly: true},
+		},
+	})
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/name", "other"))
+	require.Empty(t, sets)
+	require.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	require.NoError(t, err)
+	msgType, _, errMsg := msg.AsError()
+	require.Equal(t, core.MsgSetProperty, msgType)
+	require.Contains(t, errMsg, "read-only")
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 1))
+	require.Equal(t, []string{"count"}, sets)
+}
+
+func TestReflectSourceObjectMeta(t *testing.T) {
+	t.Parallel()
+	s, err := NewReflectSource("demo.Counter", &reflectCounter{}, nil)
+	require.NoError(t, err)
+	meta := s.ObjectMeta()
+	require.Equal(t, TypeInt, meta.Properties["count"].Type)
+	require.True(t, meta.Properties["name"].ReadOnly)
+	require.Error(t, meta.ValidateSet("unknown", 1))
+}
@@ -0,0 +1,140 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apigear-io/objectlink-core-go/log"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// Mount mounts the child registry under the module prefix. Objects with the
+// id prefix or with ids starting with "prefix." are served by the child,
+// e.g. the prefix "demo" routes "demo.Counter" to the child registry.
+// Sources, factories and links of these objects are managed by the child,
+// operations run through the interceptors of this registry and then of the child.
+// The child must not contain this registry and no object of the prefix may be
+// registered or linked yet, as the child would shadow it.
+func (r *Registry) Mount(prefix string, child *Registry) error {
+	if prefix == "" {
+		return fmt.Errorf("registry: mount prefix is empty")
+	}
+	if child == nil || child == r {
+		return fmt.Errorf("registry: invalid child registry for %s", prefix)
+	}
+	if child.contains(r) {
+		return fmt.Errorf("registry: mounting %s would create a cycle", prefix)
+	}
+	if objectId, ok := r.route(prefix).entries.findPrefix(prefix); ok {
+		return fmt.Errorf("registry: mount %s would shadow object %s", prefix, objectId)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.mounts[prefix]; ok {
+		return fmt.Errorf("registry: prefix %s is already mounted", prefix)
+	}
+	r.mounts[prefix] = child
+	log.Info().Str("prefix", prefix).Msg("registry: mount")
+	return nil
+}
+
+// Unmount removes the child registry mounted under the prefix.
+// Nodes linked to objects of the child are unlinked, nodes which
+// negotiated core.FeatureRemoteUnlink receive an unlink message,
+// other nodes an error message for the link of the object.
+func (r *Registry) Unmount(prefix string) error {
+	r.mu.Lock()
+	child, ok := r.mounts[prefix]
+	delete(r.mounts, prefix)
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("registry: prefix %s is not mounted", prefix)
+	}
+	log.Info().Str("prefix", prefix).Msg("registry: unmount")
+	child.unlinkAll()
+	return nil
+}
+
+// Mounts returns the prefixes of the mounted registries.
+func (r *Registry) Mounts() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	prefixes := make([]string, 0, len(r.mounts))
+	for prefix := range r.mounts {
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// route returns the registry serving the object,
+// which is the child with the longest matching prefix or the registry itself
+func (r *Registry) route(objectId string) *Registry {
+	r.mu.RLock()
+	var child *Registry
+	match := ""
+	for prefix, c := range r.mounts {
+		if len(prefix) <= len(match) {
+			continue
+		}
+		if objectId == prefix || strings.HasPrefix(objectId, prefix+".") {
+			child = c
+			match = prefix
+		}
+	}
+	r.mu.RUnlock()
+	if child == nil {
+		return r
+	}
+	return child.route(objectId)
+}
+
+// contains returns true if the registry is r or mounted below r
+func (r *Registry) contains(registry *Registry) bool {
+	if r == registry {
+		return true
+	}
+	for _, c := range r.children() {
+		if c.contains(registry) {
+			return true
+		}
+	}
+	return false
+}
+
+// children returns the mounted registries
+func (r *Registry) children() []*Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	children := make([]*Registry, 0, len(r.mounts))
+	for _, c := range r.mounts {
+		children = append(children, c)
+	}
+	return children
+}
+
+// unlinkAll unlinks all nodes from the objects of the registry and its children
+// and sends an unlink message to nodes which negotiated remote unlinks
+func (r *Registry) unlinkAll() {
+	for objectId, nodes := range r.entries.linkedNodes() {
+		for _, n := range nodes {
+			r.UnlinkRemoteNode(objectId, n)
+			n.sendUnlink(objectId)
+		}
+	}
+	for _, c := range r.children() {
+		c.unlinkAll()
+	}
+}
+
+// interceptChild wraps the final handler with the interceptors
+// of the child registry serving the object
+func (r *Registry) interceptChild(op *Operation, final Handler) Handler {
+	child := r.route(op.ObjectId)
+	if child == r {
+		return final
+	}
+	return func(ctx context.Context, op *Operation) (core.Any, error) {
+		return child.intercept(ctx, op, final)
+	}
+}
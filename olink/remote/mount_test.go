@@ -0,0 +1,118 @@
+package remote
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountDelegates(t *testing.T) {
+	root := NewRegistry()
+	demo := NewRegistry()
+	assert.Nil(t, root.Mount("demo", demo))
+	assert.Error(t, root.Mount("demo", NewRegistry()))
+	assert.Nil(t, root.AddObjectSource(NewMockSource("demo.Counter")))
+	assert.True(t, demo.IsRegistered("demo.Counter"))
+	assert.True(t, root.IsRegistered("demo.Counter"))
+	assert.False(t, root.IsRegistered("demox.Counter"))
+
+	n := NewNode(root)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	assert.Equal(t, []*Node{n}, demo.GetRemoteNodes("demo.Counter"))
+	assert.Equal(t, 1, wc.Count())
+
+	// notifications of the child reach the nodes of the parent
+	demo.NotifyPropertyChange("demo.Counter", core.KWArgs{"count": 1})
+	assert.Equal(t, 2, wc.Count())
+
+	n.RemoveNode()
+	assert.Equal(t, 0, len(demo.GetRemoteNodes("demo.Counter")))
+}
+
+func TestMountNested(t *testing.T) {
+	root := NewRegistry()
+	demo := NewRegistry()
+	sub := NewRegistry()
+	assert.Nil(t, root.Mount("demo", demo))
+	assert.Nil(t, demo.Mount("demo.sub", sub))
+	assert.Nil(t, root.AddObjectSource(NewMockSource("demo.sub.Counter")))
+	assert.True(t, sub.IsRegistered("demo.sub.Counter"))
+	assert.False(t, demo.entries.hasEntry("demo.sub.Counter"))
+}
+
+func TestMountInterceptors(t *testing.T) {
+	root := NewRegistry()
+	demo := NewRegistry()
+	assert.Nil(t, root.Mount("demo", demo))
+	var calls []string
+	root.Use(func(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+		calls = append(calls, "root")
+		return next(ctx, op)
+	})
+	demo.Use(func(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+		calls = append(calls, "demo")
+		return next(ctx, op)
+	})
+	root.AddObjectSource(NewMockSource("demo.Counter"))
+	root.AddObjectSource(NewMockSource("other.Counter"))
+	n := NewNode(root)
+	n.SetOutput(NewMockWriteCloser())
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	n.handleMessage(core.MakeLinkMessage("other.Counter"))
+	assert.Equal(t, []string{"root", "demo", "root"}, calls)
+}
+
+func TestUnmountNotifiesNodes(t *testing.T) {
+	root := NewRegistry()
+	demo := NewRegistry()
+	assert.Nil(t, root.Mount("demo", demo))
+	s := NewMockSource("demo.Counter")
+	unlinked := 0
+	s.UnlinkedHandler = func(objectId string, node *Node) error {
+		unlinked++
+		return nil
+	}
+	root.AddObjectSource(s)
+	n := NewNode(root)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeFeaturesMessage([]string{core.FeatureRemoteUnlink}))
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	assert.Nil(t, root.Unmount("demo"))
+	assert.Error(t, root.Unmount("demo"))
+	assert.Equal(t, 1, unlinked)
+	assert.False(t, root.IsRegistered("demo.Counter"))
+	msg, err := n.conv.FromData(wc.Messages[wc.Count()-1])
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgUnlink, msg.Type())
+	assert.Equal(t, "demo.Counter", msg.AsUnlink())
+}
+
+func TestMountRejectsCycles(t *testing.T) {
+	a := NewRegistry()
+	b := NewRegistry()
+	c := NewRegistry()
+	assert.Nil(t, a.Mount("b", b))
+	assert.Nil(t, b.Mount("b.c", c))
+	assert.ErrorContains(t, b.Mount("b.a", a), "cycle")
+	assert.ErrorContains(t, c.Mount("b.c.a", a), "cycle")
+	assert.Equal(t, []string{"b.c"}, b.Mounts())
+	assert.Empty(t, c.Mounts())
+}
+
+func TestMountRejectsShadowing(t *testing.T) {
+	root := NewRegistry()
+	assert.Nil(t, root.AddObjectSource(NewMockSource("demo.Counter")))
+	assert.ErrorContains(t, root.Mount("demo", NewRegistry()), "demo.Counter")
+	assert.Nil(t, root.Mount("demox", NewRegistry()))
+	// linked objects without a source are shadowed as well
+	n := NewNode(root)
+	root.LinkRemoteNode("other.Counter", n)
+	assert.Error(t, root.Mount("other", NewRegistry()))
+	root.UnlinkRemoteNode("other.Counter", n)
+	assert.Nil(t, root.Mount("other", NewRegistry()))
+}
@@ -2,9 +2,12 @@ package remote
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/apigear-io/objectlink-core-go/helper"
 	"github.com/apigear-io/objectlink-core-go/log"
@@ -14,6 +17,21 @@ import (
 
 var nextNodeId = helper.MakeIdGenerator("n")
 
+// supportedFeatures are the protocol features a remote node accepts
+var supportedFeatures = []string{
+	core.FeatureBatchedChanges,
+	core.FeatureRemoteUnlink,
+}
+
+var (
+	// ErrNodeClosed is returned when writing to a closed node.
+	ErrNodeClosed = errors.New("node closed")
+	// ErrNodeDraining is the reply to invokes received while the node drains.
+	ErrNodeDraining = errors.New("node draining")
+	// ErrQueueFull is returned when the incoming queue of a node is full.
+	ErrQueueFull = errors.New("node incoming queue full")
+)
+
 type Node struct {
 	sync.RWMutex
 	id       string
@@ -23,9 +41,41 @@ type Node struct {
 	incoming chan []byte
 	ctx      context.Context
 	cancel   context.CancelFunc
+	// connection metadata, e.g. connection id and remote address
+	metadata  map[string]string
+	principal *Principal
+	// features negotiated with the client
+	features map[string]bool
+	// workers limits the number of concurrent invokes, nil for in order invokes
+	workers       chan struct{}
+	invokeTimeout time.Duration
+	// async limits the number of running invokes on asynchronous sources
+	async chan struct{}
+	// serialBusy is set while a timed out in order invoke is still running,
+	// serialQueue holds the invokes waiting for it, up to serialLimit
+	serialBusy   bool
+	serialQueue  []invokeCall
+	serialLimit  int
+	overflow     OverflowPolicy
+	blockTimeout time.Duration
+	dropped      atomic.Int64
+	// outgoing queues messages when changes are coalesced, nil otherwise
+	outgoing *sendQueue
+	// flushMu serializes writing the queued messages, so they keep their order
+	flushMu sync.Mutex
+	// inflight is the number of running invokes
+	inflight int
+	// drained is closed when the node drains and no invoke is running
+	drained chan struct{}
 }
 
+// NewNode creates a new node using the node options of the registry.
 func NewNode(registry *Registry) *Node {
+	return NewNodeWithOptions(registry, registry.NodeOptions())
+}
+
+// NewNodeWithOptions creates a new node using the given options.
+func NewNodeWithOptions(registry *Registry, opts NodeOptions) *Node {
 	nodeId := nextNodeId()
 	log.Debug().Msgf("node %s: creating", nodeId)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -35,9 +85,23 @@ func NewNode(registry *Registry) *Node {
 		conv: core.MessageConverter{
 			Format: core.FormatJson,
 		},
-		incoming: make(chan []byte),
-		ctx:      ctx,
-		cancel:   cancel,
+		incoming:      make(chan []byte, queueSize(opts)),
+		ctx:           ctx,
+		cancel:        cancel,
+		metadata:      make(map[string]string),
+		features:      make(map[string]bool),
+		invokeTimeout: opts.InvokeTimeout,
+		async:         make(chan struct{}, asyncInvokes(opts)),
+		serialLimit:   max(queueSize(opts), 1),
+		overflow:      opts.Overflow,
+		blockTimeout:  opts.BlockTimeout,
+	}
+	if opts.InvokeWorkers > 1 {
+		n.workers = make(chan struct{}, opts.InvokeWorkers)
+	}
+	if opts.CoalesceChanges {
+		n.outgoing = newSendQueue(sendQueueSize(opts))
+		go n.OutgoingPump()
 	}
 	registry.AttachRemoteNode(n)
 	go n.IncomingPump()
@@ -56,6 +120,50 @@ func (n *Node) Registry() *Registry {
 	return n.registry
 }
 
+// Context returns the node context, which is cancelled when the node is closed.
+func (n *Node) Context() context.Context {
+	return n.ctx
+}
+
+// SetMetadata sets a connection metadata value, e.g. the remote address.
+func (n *Node) SetMetadata(key string, value string) {
+	n.Lock()
+	defer n.Unlock()
+	n.metadata[key] = value
+}
+
+// Metadata returns a copy of the connection metadata.
+func (n *Node) Metadata() map[string]string {
+	n.RLock()
+	defer n.RUnlock()
+	md := make(map[string]string, len(n.metadata))
+	for k, v := range n.metadata {
+		md[k] = v
+	}
+	return md
+}
+
+// SetPrincipal sets the authenticated peer of the node.
+func (n *Node) SetPrincipal(p *Principal) {
+	n.Lock()
+	defer n.Unlock()
+	n.principal = p
+}
+
+// Principal returns the authenticated peer of the node or nil.
+func (n *Node) Principal() *Principal {
+	n.RLock()
+	defer n.RUnlock()
+	return n.principal
+}
+
+// HasFeature returns true if the protocol feature was negotiated with the client.
+func (n *Node) HasFeature(feature string) bool {
+	n.RLock()
+	defer n.RUnlock()
+	return n.features[feature]
+}
+
 func (n *Node) SetOutput(out io.WriteCloser) {
 	n.Lock()
 	n.output = out
@@ -78,12 +186,121 @@ func (n *Node) Close() error {
 	return nil
 }
 
+// Write queues the data for the incoming pump.
+// It returns ErrNodeClosed when the node is closed
+// and ErrQueueFull when the message could not be queued.
 func (n *Node) Write(data []byte) (int, error) {
+	select {
+	case <-n.ctx.Done():
+		return 0, ErrNodeClosed
+	default:
+	}
+	select {
+	case n.incoming <- data:
+		return len(data), nil
+	default:
+	}
+	switch n.overflow {
+	case OverflowDropOldest:
+		return n.writeDropOldest(data)
+	case OverflowDisconnect:
+		log.Warn().Msgf("node %s: incoming queue full, disconnecting", n.id)
+		n.disconnect()
+		return 0, ErrQueueFull
+	default:
+		return n.writeBlocking(data)
+	}
+}
+
+// writeBlocking waits until the data is queued, the node is closed
+// or the block timeout expired.
+func (n *Node) writeBlocking(data []byte) (int, error) {
+	var timeout <-chan time.Time
+	if n.blockTimeout > 0 {
+		timer := time.NewTimer(n.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case n.incoming <- data:
+		return len(data), nil
+	case <-n.ctx.Done():
+		return 0, ErrNodeClosed
+	case <-timeout:
+		n.dropped.Add(1)
+		log.Warn().Msgf("node %s: incoming queue full, message dropped", n.id)
+		return 0, ErrQueueFull
+	}
+}
+
+// writeDropOldest drops queued messages until the data fits into the queue.
+func (n *Node) writeDropOldest(data []byte) (int, error) {
+	if cap(n.incoming) == 0 {
+		return n.writeBlocking(data)
+	}
+	for {
+		select {
+		case n.incoming <- data:
+			return len(data), nil
+		case <-n.ctx.Done():
+			return 0, ErrNodeClosed
+		default:
+		}
+		select {
+		case <-n.incoming:
+			n.dropped.Add(1)
+			log.Warn().Msgf("node %s: incoming queue full, oldest message dropped", n.id)
+		default:
+		}
+	}
+}
+
+// disconnect closes the node and its output.
+func (n *Node) disconnect() {
+	n.Close()
+	n.RLock()
+	output := n.output
+	n.RUnlock()
+	if output != nil {
+		output.Close()
+	}
+}
+
+// Dropped returns the number of messages dropped due to overflow
+// of the incoming or the outgoing queue.
+func (n *Node) Dropped() int64 {
+	dropped := n.dropped.Load()
+	if n.outgoing != nil {
+		dropped += n.outgoing.droppedCount()
+	}
+	return dropped
+}
+
+// SetMessageFormat sets the format used to encode and decode messages.
+func (n *Node) SetMessageFormat(format core.MessageFormat) {
+	n.Lock()
+	defer n.Unlock()
+	n.conv = core.MessageConverter{Format: format}
+}
+
+// MessageFormat returns the format used to encode and decode messages.
+func (n *Node) MessageFormat() core.MessageFormat {
+	n.RLock()
+	defer n.RUnlock()
+	return n.conv.Format
+}
+
+// WriteFrame handles a message received in a transport frame.
+// Text and binary frames are both accepted, the data is always
+// decoded using the message format of the node.
+func (n *Node) WriteFrame(frame core.FrameType, data []byte) (int, error) {
 	n.RLock()
-	incoming := n.incoming
+	format := n.conv.Format
 	n.RUnlock()
-	incoming <- data
-	return len(data), nil
+	if frame != format.FrameType() {
+		log.Debug().Msgf("node %s: %s frame for %s messages", n.id, frame, format)
+	}
+	return n.Write(data)
 }
 
 func (n *Node) IncomingPump() {
@@ -92,103 +309,668 @@ func (n *Node) IncomingPump() {
 		case <-n.ctx.Done():
 			return
 		case data := <-n.incoming:
-			msg, err := n.conv.FromData(data)
-			if err != nil {
+			n.RLock()
+			conv := n.conv
+			n.RUnlock()
+			msg, err := conv.FromData(data)
+			if err != nil || len(msg) == 0 {
+				log.Warn().Msgf("node %s: invalid message: %v", n.id, err)
 				continue
 			}
-			switch msg.Type() {
-			case core.MsgLink:
-				objectId := msg.AsLink()
-				n.registry.LinkRemoteNode(objectId, n)
-				s := n.registry.GetObjectSource(objectId)
-				if s == nil {
-					break
-				}
-				s.Linked(objectId, n)
-				// send back an init message
-
-				props, err := s.CollectProperties()
-				if err != nil {
-					break
-				}
-				msg := core.MakeInitMessage(objectId, props)
-				n.SendMessage(msg)
-			case core.MsgUnlink:
-				// unlink the sink from the source
-				objectId := msg.AsUnlink()
-				n.registry.UnlinkRemoteNode(objectId, n)
-			case core.MsgSetProperty:
-				// set the property on the source
-				propertyId, value := msg.AsSetProperty()
-				objectId, name := core.SymbolIdToParts(propertyId)
-				s := n.registry.GetObjectSource(objectId)
-				if s == nil {
-					break
-				}
-				s.SetProperty(name, value)
-				// send back property change message
-				msg := core.MakePropertyChangeMessage(propertyId, value)
-				n.SendMessage(msg)
-			case core.MsgInvoke:
-				// invoke the method on the source
-				requestId, methodId, args := msg.AsInvoke()
-				objectId, name := core.SymbolIdToParts(methodId)
-				s := n.registry.GetObjectSource(objectId)
-				if s == nil {
-					log.Warn().Msgf("node: no source for %s", objectId)
-					break
-				}
-				result, err := s.Invoke(name, args)
-				if err != nil {
-					log.Warn().Msgf("node: error invoking %s: %v", methodId, err)
-					msg := core.MakeErrorMessage(core.MsgInvoke, requestId, err.Error())
-					n.SendMessage(msg)
-					break
-				}
-				log.Debug().Msgf("node: invoke result: %v", result)
-				msg := core.MakeInvokeReplyMessage(requestId, methodId, result)
-				n.SendMessage(msg)
-			case core.MsgSignal:
-				// send the signal to all nodes
-				signalId, args := msg.AsSignal()
-				if n.registry != nil {
-					objectId, name := core.SymbolIdToParts(signalId)
-					n.registry.NotifySignal(objectId, name, args)
-				} else {
-					n.SendSignal(signalId, args)
-				}
-			default:
-				log.Info().Msgf("node: unknown message type: %v", msg.Type())
+			n.handleMessage(msg)
+		}
+	}
+}
+
+// handleMessage dispatches a decoded message to the handler of its type.
+func (n *Node) handleMessage(msg core.Message) {
+	switch msg.Type() {
+	case core.MsgFeatures:
+		n.handleFeatures(msg.AsFeatures())
+	case core.MsgLink:
+		n.handleLink(msg.AsLink())
+	case core.MsgUnlink:
+		n.handleUnlink(msg.AsUnlink())
+	case core.MsgSetProperty:
+		propertyId, value := msg.AsSetProperty()
+		n.handleSetProperty(propertyId, value)
+	case core.MsgInvoke:
+		requestId, methodId, args := msg.AsInvoke()
+		n.handleInvoke(requestId, methodId, args)
+	case core.MsgSignal:
+		signalId, args := msg.AsSignal()
+		n.handleSignal(signalId, args)
+	default:
+		log.Info().Msgf("node: unknown message type: %v", msg.Type())
+	}
+}
+
+// handleFeatures accepts the requested features which are supported
+// and replies with the accepted features
+func (n *Node) handleFeatures(requested []string) {
+	accepted := []string{}
+	n.Lock()
+	for _, f := range requested {
+		for _, sf := range supportedFeatures {
+			if f == sf {
+				n.features[f] = true
+				accepted = append(accepted, f)
+			}
+		}
+	}
+	n.Unlock()
+	n.SendMessage(core.MakeFeaturesMessage(accepted))
+}
+
+// handleLink runs the link operation through the interceptors
+func (n *Node) handleLink(objectId string) {
+	op := &Operation{Kind: OpLink, Node: n, ObjectId: objectId}
+	n.handleOperation(op, n.doLink)
+}
+
+// doLink links the node to the source and sends back an init message
+func (n *Node) doLink(ctx context.Context, op *Operation) (core.Any, error) {
+	objectId := op.ObjectId
+	var s IObjectSource
+	for attempt := 0; ; attempt++ {
+		s = n.registry.GetObjectSource(objectId)
+		if n.registry.linkRemoteNode(objectId, n, s) {
+			break
+		}
+		// the source was evicted before the node was linked
+		if attempt == 2 {
+			return nil, fmt.Errorf("source %s was removed while linking", objectId)
+		}
+	}
+	if s == nil {
+		return nil, nil
+	}
+	s.Linked(objectId, n)
+	return nil, n.sendInit(objectId, s)
+}
+
+// sendUnlink informs the client that the object was removed.
+// Clients which did not negotiate remote unlinks do not know the unlink
+// message and receive an error message for the link instead.
+func (n *Node) sendUnlink(objectId string) {
+	if !n.HasFeature(core.FeatureRemoteUnlink) {
+		n.SendMessage(core.MakeErrorMessage(core.MsgLink, 0, fmt.Sprintf("%s: object removed", objectId)))
+		return
+	}
+	n.SendMessage(core.MakeUnlinkMessage(objectId))
+}
+
+// sendInit sends an init message with the properties of the source
+func (n *Node) sendInit(objectId string, s IObjectSource) error {
+	props, err := s.CollectProperties()
+	if err != nil {
+		return err
+	}
+	if meta := n.registry.ObjectMeta(objectId); meta != nil {
+		props = meta.ApplyDefaults(props)
+	}
+	msg := core.MakeInitMessage(objectId, props)
+	n.SendMessage(msg)
+	return nil
+}
+
+// handleUnlink runs the unlink operation through the interceptors
+func (n *Node) handleUnlink(objectId string) {
+	op := &Operation{Kind: OpUnlink, Node: n, ObjectId: objectId}
+	n.handleOperation(op, n.doUnlink)
+}
+
+// doUnlink unlinks the sink from the source
+func (n *Node) doUnlink(ctx context.Context, op *Operation) (core.Any, error) {
+	n.registry.UnlinkRemoteNode(op.ObjectId, n)
+	return nil, nil
+}
+
+// handleSetProperty runs the set operation through the interceptors
+// and sends back a property change message on success
+func (n *Node) handleSetProperty(propertyId string, value core.Any) {
+	objectId, name := core.SymbolIdToParts(propertyId)
+	op := &Operation{Kind: OpSetProperty, Node: n, ObjectId: objectId, Member: name, Value: value}
+	if !n.handleOperation(op, n.doSetProperty) {
+		return
+	}
+	// send back property change message
+	msg := core.MakePropertyChangeMessage(op.SymbolId(), op.Value)
+	n.SendMessage(msg)
+}
+
+// doSetProperty validates the value and sets the property on the source
+func (n *Node) doSetProperty(ctx context.Context, op *Operation) (core.Any, error) {
+	s := n.registry.GetObjectSource(op.ObjectId)
+	if s == nil {
+		return nil, fmt.Errorf("no source for %s", op.ObjectId)
+	}
+	if meta := n.registry.ObjectMeta(op.ObjectId); meta != nil {
+		err := meta.ValidateSet(op.Member, op.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := setSourceProperty(ctx, s, op.Member, op.Value)
+	// record the accepted set with its outcome, a failed set carries the error
+	e := JournalEntry{Kind: JournalSet, NodeId: n.id, ObjectId: op.ObjectId, Member: op.Member, Value: op.Value}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	n.registry.record(e)
+	if err != nil {
+		return nil, err
+	}
+	n.registry.propertiesChanged(op.ObjectId)
+	return nil, nil
+}
+
+// handleSignal runs the signal operation through the interceptors
+func (n *Node) handleSignal(signalId string, args core.Args) {
+	objectId, name := core.SymbolIdToParts(signalId)
+	op := &Operation{Kind: OpSignal, Node: n, ObjectId: objectId, Member: name, Args: args}
+	n.handleOperation(op, n.doSignal)
+}
+
+// doSignal sends the signal to all nodes
+func (n *Node) doSignal(ctx context.Context, op *Operation) (core.Any, error) {
+	if n.registry != nil {
+		n.registry.notifySignal(op.ObjectId, op.Member, op.Args, n.id)
+	} else {
+		n.SendSignal(op.SymbolId(), op.Args)
+	}
+	return nil, nil
+}
+
+// handleOperation runs the operation through the interceptors.
+// On error an error message is sent back and false is returned.
+func (n *Node) handleOperation(op *Operation, final Handler) bool {
+	ctx, cancel := n.callContext(0)
+	defer cancel()
+	_, err := n.registry.intercept(ctx, op, final)
+	if err != nil {
+		log.Warn().Msgf("node %s: %s %s failed: %v", n.id, op.Kind, op.SymbolId(), err)
+		msg := core.MakeErrorMessage(op.Kind.MsgType(), op.RequestId, fmt.Sprintf("%s: %v", op.SymbolId(), err))
+		n.SendMessage(msg)
+		return false
+	}
+	return true
+}
+
+// invokeCall is an invoke request received by the node
+type invokeCall struct {
+	requestId int64
+	methodId  string
+	args      core.Args
+}
+
+// handleInvoke invokes the method on the source and sends back the reply.
+// If the node has invoke workers, the invoke runs concurrently
+// and the pump only blocks while all workers are busy.
+// Invokes on registered asynchronous sources do not block the pump
+// unless the limit of running asynchronous invokes is reached.
+// The source is looked up or created by doInvoke after the interceptors
+// authorized the invoke, so a denied invoke never reaches a source factory.
+func (n *Node) handleInvoke(requestId int64, methodId string, args core.Args) {
+	if !n.beginInvoke() {
+		n.sendInvokeResult(requestId, methodId, nil, fmt.Errorf("%s: %w", methodId, ErrNodeDraining))
+		return
+	}
+	call := invokeCall{requestId: requestId, methodId: methodId, args: args}
+	objectId := core.SymbolIdToObjectId(methodId)
+	if _, ok := n.registry.lookupSource(objectId).(IAsyncSource); ok {
+		if !n.acquire(n.async) {
+			return
+		}
+		go func() {
+			defer func() { <-n.async }()
+			n.awaitInvoke(call)
+		}()
+		return
+	}
+	if n.workers == nil {
+		n.invokeInOrder(call)
+		return
+	}
+	if !n.acquire(n.workers) {
+		return
+	}
+	go func() {
+		defer func() { <-n.workers }()
+		n.awaitInvoke(call)
+	}()
+}
+
+// acquire takes a slot of the semaphore, it returns false
+// and ends the invoke if the node was closed first
+func (n *Node) acquire(slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-n.ctx.Done():
+		n.endInvoke()
+		return false
+	}
+}
+
+// awaitInvoke runs the invoke and waits until a timed out source call returned,
+// so a worker is not reused while the source is still busy
+func (n *Node) awaitInvoke(call invokeCall) {
+	running := n.invoke(call)
+	if running == nil {
+		return
+	}
+	select {
+	case <-running:
+	case <-n.ctx.Done():
+	}
+}
+
+// invokeInOrder runs the invoke on the pump. If a timed out invoke is still
+// running, the invoke is queued and runs after it, so in order invokes never
+// overlap while the pump keeps processing sets and links.
+func (n *Node) invokeInOrder(call invokeCall) {
+	n.Lock()
+	if n.serialBusy {
+		if len(n.serialQueue) >= n.serialLimit {
+			n.Unlock()
+			n.endInvoke()
+			n.sendInvokeResult(call.requestId, call.methodId, nil, fmt.Errorf("%s: %w", call.methodId, ErrQueueFull))
+			return
+		}
+		n.serialQueue = append(n.serialQueue, call)
+		n.Unlock()
+		return
+	}
+	n.Unlock()
+	running := n.invoke(call)
+	if running == nil {
+		return
+	}
+	n.Lock()
+	n.serialBusy = true
+	n.Unlock()
+	go n.runQueuedInvokes(running)
+}
+
+// runQueuedInvokes waits for the running source call and then runs
+// the queued in order invokes until the queue is empty
+func (n *Node) runQueuedInvokes(running <-chan struct{}) {
+	for {
+		select {
+		case <-running:
+		case <-n.ctx.Done():
+			n.Lock()
+			queued := n.serialQueue
+			n.serialQueue = nil
+			n.serialBusy = false
+			n.Unlock()
+			for range queued {
+				n.endInvoke()
 			}
+			return
+		}
+		n.Lock()
+		if len(n.serialQueue) == 0 {
+			n.serialBusy = false
+			n.Unlock()
+			return
 		}
+		call := n.serialQueue[0]
+		n.serialQueue = n.serialQueue[1:]
+		n.Unlock()
+		running = n.invoke(call)
+		if running == nil {
+			running = closedChan
+		}
+	}
+}
+
+// closedChan is a closed channel
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// invoke runs the invoke operation through the interceptors
+// and sends the reply or error message unless the node was closed.
+// If a timed out source call is still running, it returns a channel
+// which is closed when the call returned.
+func (n *Node) invoke(call invokeCall) <-chan struct{} {
+	defer n.endInvoke()
+	objectId, name := core.SymbolIdToParts(call.methodId)
+	op := &Operation{Kind: OpInvoke, Node: n, ObjectId: objectId, Member: name, RequestId: call.requestId, Args: call.args}
+	ctx, cancel := n.callContext(n.invokeTimeout)
+	defer cancel()
+	// running is set when the source call is still running after a timeout
+	var running <-chan struct{}
+	final := func(ctx context.Context, op *Operation) (core.Any, error) {
+		return n.doInvoke(ctx, op, &running)
+	}
+	result, err := n.registry.intercept(ctx, op, final)
+	n.recordInvoke(op, result, err)
+	if n.ctx.Err() != nil {
+		return running
+	}
+	n.sendInvokeResult(call.requestId, call.methodId, result, err)
+	return running
+}
+
+// asyncInvokes returns the limit of running invokes on asynchronous sources
+func asyncInvokes(opts NodeOptions) int {
+	if opts.AsyncInvokes <= 0 {
+		return DefaultNodeOptions().AsyncInvokes
+	}
+	return opts.AsyncInvokes
+}
+
+// beginInvoke counts a running invoke.
+// It returns false if the node drains and no new invokes are accepted.
+func (n *Node) beginInvoke() bool {
+	n.Lock()
+	defer n.Unlock()
+	if n.drained != nil {
+		return false
+	}
+	n.inflight++
+	return true
+}
+
+// endInvoke counts a finished invoke
+func (n *Node) endInvoke() {
+	n.Lock()
+	defer n.Unlock()
+	n.inflight--
+	if n.inflight == 0 && n.drained != nil {
+		close(n.drained)
+	}
+}
+
+// Drain stops accepting invokes and waits until the running invokes
+// sent their replies. Invokes received while draining are answered
+// with an error. It returns the number of invokes still running
+// when the context is done. With coalesced changes the replies may
+// still be queued when Drain returns, see Flush.
+func (n *Node) Drain(ctx context.Context) int {
+	n.Lock()
+	if n.drained == nil {
+		n.drained = make(chan struct{})
+		if n.inflight == 0 {
+			close(n.drained)
+		}
+	}
+	drained := n.drained
+	n.Unlock()
+	select {
+	case <-drained:
+		return 0
+	case <-ctx.Done():
+		n.RLock()
+		defer n.RUnlock()
+		return n.inflight
+	}
+}
+
+// doInvoke calls the source and waits for the result.
+// It returns an error when the invoke timed out or the node was closed.
+// If a synchronous source call is still running, running is set
+// to a channel which is closed when the call returned.
+func (n *Node) doInvoke(ctx context.Context, op *Operation, running *<-chan struct{}) (core.Any, error) {
+	s := n.registry.GetObjectSource(op.ObjectId)
+	if s == nil {
+		return nil, fmt.Errorf("no source for %s", op.ObjectId)
+	}
+	_, isAsync := s.(IAsyncSource)
+	if !isAsync && n.invokeTimeout <= 0 {
+		return invokeSource(ctx, s, op.Member, op.Args)
+	}
+	type invokeResult struct {
+		result core.Any
+		err    error
+	}
+	done := make(chan invokeResult, 1)
+	var once sync.Once
+	reply := func(result core.Any, err error) {
+		once.Do(func() {
+			done <- invokeResult{result, err}
+		})
+	}
+	var returned chan struct{}
+	if as, ok := s.(IAsyncSource); ok {
+		as.InvokeAsync(ctx, op.Member, op.Args, reply)
+	} else {
+		returned = make(chan struct{})
+		go func() {
+			defer close(returned)
+			reply(invokeSource(ctx, s, op.Member, op.Args))
+		}()
+	}
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-ctx.Done():
+		if returned != nil {
+			*running = returned
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("invoke %s timed out after %s", op.SymbolId(), n.invokeTimeout)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// recordInvoke records the invoke with its result or error in the journal
+func (n *Node) recordInvoke(op *Operation, result core.Any, err error) {
+	if n.registry == nil {
+		return
 	}
+	e := JournalEntry{Kind: JournalInvoke, NodeId: n.id, ObjectId: op.ObjectId, Member: op.Member, Args: op.Args, Value: result}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	n.registry.record(e)
+}
+
+// sendInvokeResult sends an invoke reply or an error message
+func (n *Node) sendInvokeResult(requestId int64, methodId string, result core.Any, err error) {
+	if err != nil {
+		log.Warn().Msgf("node: error invoking %s: %v", methodId, err)
+		msg := core.MakeErrorMessage(core.MsgInvoke, requestId, err.Error())
+		n.SendMessage(msg)
+		return
+	}
+	log.Debug().Msgf("node: invoke result: %v", result)
+	msg := core.MakeInvokeReplyMessage(requestId, methodId, result)
+	n.SendMessage(msg)
+}
+
+// queueSize returns the size of the incoming queue
+func queueSize(opts NodeOptions) int {
+	if opts.QueueSize < 0 {
+		return 0
+	}
+	return opts.QueueSize
+}
+
+// sendQueueSize returns the limit of the outgoing queue
+func sendQueueSize(opts NodeOptions) int {
+	if opts.SendQueueSize <= 0 {
+		return DefaultNodeOptions().SendQueueSize
+	}
+	return opts.SendQueueSize
+}
+
+// callContext returns a context for a source call.
+// It is cancelled when the node is closed or the timeout expired
+// and carries the caller. A zero timeout means no timeout.
+func (n *Node) callContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	caller := &Caller{
+		Node:      n,
+		Metadata:  n.Metadata(),
+		Principal: n.Principal(),
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(n.ctx, timeout)
+		return WithCaller(ctx, caller), cancel
+	}
+	ctx, cancel := context.WithCancel(n.ctx)
+	return WithCaller(ctx, caller), cancel
+}
+
+// invokeSource calls the context aware invoke if the source supports it
+func invokeSource(ctx context.Context, s IObjectSource, name string, args core.Args) (core.Any, error) {
+	if cs, ok := s.(IContextSource); ok {
+		return cs.InvokeContext(ctx, name, args)
+	}
+	return s.Invoke(name, args)
+}
+
+// setSourceProperty calls the context aware set property if the source supports it
+func setSourceProperty(ctx context.Context, s IObjectSource, name string, value core.Any) error {
+	if cs, ok := s.(IContextSource); ok {
+		return cs.SetPropertyContext(ctx, name, value)
+	}
+	return s.SetProperty(name, value)
 }
 
 func (n *Node) SendMessage(msg core.Message) {
-	log.Debug().Msgf("-> %s send %v", n.id, msg)
+	n.sendEncoded(newEncodedMessage(msg))
+}
+
+// sendEncoded writes the message encoded in the format of the node.
+// If changes are coalesced, the data is queued for the outgoing pump.
+func (n *Node) sendEncoded(m *encodedMessage) {
+	log.Debug().Msgf("-> %s send %v", n.id, m.msg)
 	n.RLock()
 	output := n.output
 	conv := n.conv
 	n.RUnlock()
-	err := doSendMessage(output, conv, msg)
+	if n.outgoing != nil {
+		n.queueEncoded(conv, m)
+		return
+	}
+	err := doSendMessage(output, conv, m)
 	if err != nil {
 		log.Error().Msgf("node: error sending message: %v", err)
 	}
 }
 
-func doSendMessage(o io.WriteCloser, c core.MessageConverter, msg core.Message) error {
+// queueEncoded encodes the message and queues it for the outgoing pump
+func (n *Node) queueEncoded(conv core.MessageConverter, m *encodedMessage) {
+	if m.msg == nil {
+		log.Error().Msgf("node: error sending message: no message")
+		return
+	}
+	data, err := m.encode(conv)
+	if err != nil {
+		log.Error().Msgf("node: error converting message: %v", err)
+		return
+	}
+	key := ""
+	if m.msg.Type() == core.MsgPropertyChange {
+		key, _ = m.msg.AsPropertyChange()
+	}
+	if n.outgoing.push(key, data) {
+		return
+	}
+	switch n.overflow {
+	case OverflowDropOldest:
+		log.Warn().Msgf("node %s: outgoing queue full, oldest message dropped", n.id)
+		n.outgoing.pushDropOldest(key, data)
+	case OverflowDisconnect:
+		log.Warn().Msgf("node %s: outgoing queue full, disconnecting", n.id)
+		n.outgoing.drop()
+		n.disconnect()
+	default:
+		n.queueBlocking(key, data)
+	}
+}
+
+// queueBlocking waits until the outgoing pump made room for the data,
+// the node is closed or the block timeout expired.
+func (n *Node) queueBlocking(key string, data []byte) {
+	var timeout <-chan time.Time
+	if n.blockTimeout > 0 {
+		timer := time.NewTimer(n.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		select {
+		case <-n.outgoing.space:
+			if n.outgoing.push(key, data) {
+				return
+			}
+		case <-n.ctx.Done():
+			return
+		case <-timeout:
+			n.outgoing.drop()
+			log.Warn().Msgf("node %s: outgoing queue full, message dropped", n.id)
+			return
+		}
+	}
+}
+
+// OutgoingPump writes queued messages to the output until the node is closed.
+// It only runs if changes are coalesced.
+func (n *Node) OutgoingPump() {
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-n.outgoing.ready:
+			n.Flush()
+		}
+	}
+}
+
+// Flush writes the queued outgoing messages to the output and returns
+// when they were handed to the output. Without coalesced changes
+// messages are not queued and Flush returns immediately.
+func (n *Node) Flush() {
+	if n.outgoing == nil {
+		return
+	}
+	n.flushMu.Lock()
+	defer n.flushMu.Unlock()
+	for _, item := range n.outgoing.popAll() {
+		n.RLock()
+		output := n.output
+		frame := n.conv.Format.FrameType()
+		n.RUnlock()
+		if output == nil {
+			log.Error().Msgf("node: error sending message: no output")
+			continue
+		}
+		_, err := core.WriteFrame(output, frame, item.data)
+		if err != nil {
+			log.Error().Msgf("node: error writing message: %v", err)
+		}
+	}
+}
+
+// Coalesced returns the number of property changes replaced by a newer value
+// before they were written.
+func (n *Node) Coalesced() int64 {
+	if n.outgoing == nil {
+		return 0
+	}
+	return n.outgoing.coalescedCount()
+}
+
+func doSendMessage(o io.WriteCloser, c core.MessageConverter, m *encodedMessage) error {
 	if o == nil {
 		return fmt.Errorf("no output")
 	}
-	if msg == nil {
+	if m.msg == nil {
 		return fmt.Errorf("no message")
 	}
-
-	data, err := c.ToData(msg)
+	data, err := m.encode(c)
 	if err != nil {
 		return fmt.Errorf("error converting message: %v", err)
 	}
-	_, err = o.Write(data)
+	_, err = core.WriteFrame(o, c.Format.FrameType(), data)
 	if err != nil {
 		return fmt.Errorf("error writing message: %v", err)
 	}
@@ -1,7 +1,11 @@
 package remote
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/apigear-io/objectlink-core-go/olink/core"
 	"github.com/stretchr/testify/assert"
@@ -58,3 +62,467 @@ func TestRemoveNode(t *testing.T) {
 	n.RemoveNode()
 	assert.Equal(t, 0, len(r.GetRemoteNodes(s.ObjectId())))
 }
+
+type contextSource struct {
+	*MockSource
+	caller *Caller
+}
+
+func (s *contextSource) InvokeContext(ctx context.Context, methodId string, args core.Args) (core.Any, error) {
+	s.caller, _ = CallerFromContext(ctx)
+	return methodId, nil
+}
+
+func (s *contextSource) SetPropertyContext(ctx context.Context, propertyId string, value core.Any) error {
+	s.caller, _ = CallerFromContext(ctx)
+	return nil
+}
+
+func TestNodeInvokeContext(t *testing.T) {
+	r := NewRegistry()
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.SetMetadata("remote_addr", "127.0.0.1")
+	n.SetPrincipal(&Principal{Name: "alice", Roles: []string{"admin"}})
+	s := &contextSource{MockSource: NewMockSource("demo.Counter")}
+	r.AddObjectSource(s)
+	n.handleMessage(core.MakeInvokeMessage(1, "demo.Counter/increment", core.Args{}))
+	assert.NotNil(t, s.caller)
+	assert.Equal(t, n, s.caller.Node)
+	assert.Equal(t, "127.0.0.1", s.caller.Metadata["remote_addr"])
+	assert.True(t, s.caller.Principal.HasRole("admin"))
+	assert.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	_, _, value := msg.AsInvokeReply()
+	assert.Equal(t, "increment", value)
+}
+
+func TestNodeCallContextCancelledOnClose(t *testing.T) {
+	r := NewRegistry()
+	n := NewNode(r)
+	ctx, cancel := n.callContext(0)
+	defer cancel()
+	assert.Equal(t, n, NodeFromContext(ctx))
+	n.Close()
+	<-ctx.Done()
+	assert.Error(t, ctx.Err())
+}
+
+type asyncSource struct {
+	*MockSource
+	// handler keeps the reply instead of replying with the method id
+	handler func(reply func(core.Any, error))
+}
+
+func (s *asyncSource) InvokeAsync(ctx context.Context, methodId string, args core.Args, reply ReplyFunc) {
+	if s.handler != nil {
+		s.handler(reply)
+		return
+	}
+	go func() {
+		reply(methodId, nil)
+	}()
+}
+
+func writeMessage(t *testing.T, n *Node, msg core.Message) {
+	data, err := n.conv.ToData(msg)
+	assert.Nil(t, err)
+	_, err = n.Write(data)
+	assert.Nil(t, err)
+}
+
+func TestNodeConcurrentInvoke(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{InvokeWorkers: 2})
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	release := make(chan struct{})
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		if methodId == "slow" {
+			<-release
+		}
+		return methodId, nil
+	}
+	r.AddObjectSource(s)
+	writeMessage(t, n, core.MakeInvokeMessage(1, "demo.Counter/slow", core.Args{}))
+	writeMessage(t, n, core.MakeInvokeMessage(2, "demo.Counter/fast", core.Args{}))
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	requestId, _, _ := msg.AsInvokeReply()
+	assert.Equal(t, int64(2), requestId)
+	close(release)
+	assert.Eventually(t, func() bool { return wc.Count() == 2 }, time.Second, time.Millisecond)
+}
+
+func TestNodeInvokeTimeout(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{InvokeTimeout: 10 * time.Millisecond})
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	release := make(chan struct{})
+	defer close(release)
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		<-release
+		return nil, nil
+	}
+	r.AddObjectSource(s)
+	writeMessage(t, n, core.MakeInvokeMessage(1, "demo.Counter/slow", core.Args{}))
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgError, msg.Type())
+}
+
+func TestNodeInvokeTimeoutKeepsOrder(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{InvokeTimeout: 10 * time.Millisecond, QueueSize: 4})
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	release := make(chan struct{})
+	var running atomic.Bool
+	var overlapped atomic.Bool
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		if running.Swap(true) {
+			overlapped.Store(true)
+		}
+		defer running.Store(false)
+		if methodId == "slow" {
+			<-release
+		}
+		return methodId, nil
+	}
+	r.AddObjectSource(s)
+	writeMessage(t, n, core.MakeInvokeMessage(1, "demo.Counter/slow", core.Args{}))
+	writeMessage(t, n, core.MakeInvokeMessage(2, "demo.Counter/fast", core.Args{}))
+	writeMessage(t, n, core.MakeSetPropertyMessage("demo.Counter/count", 1))
+	// the pump answers the timed out invoke and continues with the set
+	assert.Eventually(t, func() bool { return wc.Count() == 2 }, time.Second, time.Millisecond)
+	msg, err := n.conv.FromData(wc.Messages[1])
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgPropertyChange, msg.Type())
+	// the next invoke waits for the timed out call
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 2, wc.Count())
+	close(release)
+	assert.Eventually(t, func() bool { return wc.Count() == 3 }, time.Second, time.Millisecond)
+	assert.False(t, overlapped.Load())
+	msg, err = n.conv.FromData(wc.Messages[2])
+	assert.Nil(t, err)
+	requestId, _, _ := msg.AsInvokeReply()
+	assert.Equal(t, int64(2), requestId)
+}
+
+func TestNodeInvokeTimeoutQueueFull(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{InvokeTimeout: 10 * time.Millisecond, QueueSize: 1})
+	defer n.Close()
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	release := make(chan struct{})
+	defer close(release)
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		<-release
+		return nil, nil
+	}
+	r.AddObjectSource(s)
+	writeMessage(t, n, core.MakeInvokeMessage(1, "demo.Counter/slow", core.Args{}))
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	writeMessage(t, n, core.MakeInvokeMessage(2, "demo.Counter/queued", core.Args{}))
+	writeMessage(t, n, core.MakeInvokeMessage(3, "demo.Counter/rejected", core.Args{}))
+	assert.Eventually(t, func() bool { return wc.Count() == 2 }, time.Second, time.Millisecond)
+	msg, err := n.conv.FromData(wc.Messages[1])
+	assert.Nil(t, err)
+	_, requestId, reason := msg.AsError()
+	assert.Equal(t, int64(3), requestId)
+	assert.Contains(t, reason, ErrQueueFull.Error())
+}
+
+func TestNodeInvokeAsync(t *testing.T) {
+	r := NewRegistry()
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	s := &asyncSource{MockSource: NewMockSource("demo.Counter")}
+	r.AddObjectSource(s)
+	writeMessage(t, n, core.MakeInvokeMessage(1, "demo.Counter/increment", core.Args{}))
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	_, _, value := msg.AsInvokeReply()
+	assert.Equal(t, "increment", value)
+}
+
+func TestNodeInvokeAsyncLimit(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{AsyncInvokes: 2, QueueSize: 4})
+	defer n.Close()
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	var replies []func()
+	var mu sync.Mutex
+	s := &asyncSource{MockSource: NewMockSource("demo.Counter")}
+	s.handler = func(reply func(core.Any, error)) {
+		mu.Lock()
+		defer mu.Unlock()
+		replies = append(replies, func() { reply(nil, nil) })
+	}
+	r.AddObjectSource(s)
+	for i := 1; i <= 3; i++ {
+		writeMessage(t, n, core.MakeInvokeMessage(int64(i), "demo.Counter/pending", core.Args{}))
+	}
+	pending := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(replies)
+	}
+	assert.Eventually(t, func() bool { return pending() == 2 }, time.Second, time.Millisecond)
+	// the third invoke waits for a free slot
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 2, pending())
+	mu.Lock()
+	replies[0]()
+	mu.Unlock()
+	assert.Eventually(t, func() bool { return pending() == 3 }, time.Second, time.Millisecond)
+}
+
+// blockingNode returns a node whose pump is blocked until release is closed
+func blockingNode(t *testing.T, opts NodeOptions) (*Node, *MockWriteCloser, chan struct{}) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, opts)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s := NewMockSource("demo.Counter")
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}
+	r.AddObjectSource(s)
+	writeMessage(t, n, core.MakeInvokeMessage(1, "demo.Counter/block", core.Args{}))
+	<-started
+	return n, wc, release
+}
+
+func TestNodeWriteAfterClose(t *testing.T) {
+	r := NewRegistry()
+	n := NewNode(r)
+	n.Close()
+	_, err := n.Write([]byte("[]"))
+	assert.ErrorIs(t, err, ErrNodeClosed)
+}
+
+func TestNodeOverflowBlockTimeout(t *testing.T) {
+	n, _, release := blockingNode(t, NodeOptions{QueueSize: 1, BlockTimeout: time.Millisecond})
+	defer close(release)
+	_, err := n.Write([]byte("[]"))
+	assert.Nil(t, err)
+	_, err = n.Write([]byte("[]"))
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, int64(1), n.Dropped())
+}
+
+func TestNodeOverflowDropOldest(t *testing.T) {
+	n, _, release := blockingNode(t, NodeOptions{QueueSize: 1, Overflow: OverflowDropOldest})
+	defer close(release)
+	for i := 0; i < 3; i++ {
+		_, err := n.Write([]byte("[]"))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(2), n.Dropped())
+}
+
+func TestNodeOverflowDisconnect(t *testing.T) {
+	n, wc, release := blockingNode(t, NodeOptions{QueueSize: 1, Overflow: OverflowDisconnect})
+	defer close(release)
+	_, err := n.Write([]byte("[]"))
+	assert.Nil(t, err)
+	_, err = n.Write([]byte("[]"))
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.True(t, wc.Closed)
+	_, err = n.Write([]byte("[]"))
+	assert.ErrorIs(t, err, ErrNodeClosed)
+}
+
+func TestNodeCoalesceChanges(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{CoalesceChanges: true})
+	defer n.Close()
+	wc := NewMockWriteCloser()
+	release := make(chan struct{})
+	wc.WriteHandler = func(p []byte) (int, error) {
+		<-release
+		return len(p), nil
+	}
+	n.SetOutput(wc)
+	n.SendPropertyChange("demo.Counter/count", 1)
+	// the pump is blocked writing the first change
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	n.SendPropertyChange("demo.Counter/count", 2)
+	n.SendSignal("demo.Counter/reset", core.Args{})
+	n.SendPropertyChange("demo.Counter/count", 3)
+	n.SendPropertyChange("demo.Counter/count", 4)
+	close(release)
+	assert.Eventually(t, func() bool { return wc.Count() == 4 }, time.Second, time.Millisecond)
+	// only the change queued after the signal is coalesced
+	assert.Equal(t, int64(1), n.Coalesced())
+	var sent []any
+	for _, data := range wc.Messages {
+		msg, err := n.conv.FromData(data)
+		assert.Nil(t, err)
+		if msg.Type() == core.MsgSignal {
+			sent = append(sent, "reset")
+			continue
+		}
+		_, value := msg.AsPropertyChange()
+		sent = append(sent, core.AsInt(value))
+	}
+	assert.Equal(t, []any{int64(1), int64(2), "reset", int64(4)}, sent)
+}
+
+func TestNodeSendQueueOverflow(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{CoalesceChanges: true, SendQueueSize: 2, Overflow: OverflowDropOldest})
+	defer n.Close()
+	wc := NewMockWriteCloser()
+	release := make(chan struct{})
+	wc.WriteHandler = func(p []byte) (int, error) {
+		<-release
+		return len(p), nil
+	}
+	n.SetOutput(wc)
+	n.SendSignal("demo.Counter/tick", core.Args{0})
+	// the pump is blocked writing the first signal
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	for i := 1; i <= 4; i++ {
+		n.SendSignal("demo.Counter/tick", core.Args{i})
+	}
+	assert.Equal(t, int64(2), n.Dropped())
+	close(release)
+	assert.Eventually(t, func() bool { return wc.Count() == 3 }, time.Second, time.Millisecond)
+	var ticks []int64
+	for _, data := range wc.Messages {
+		msg, err := n.conv.FromData(data)
+		assert.Nil(t, err)
+		_, args := msg.AsSignal()
+		ticks = append(ticks, core.AsInt(args[0]))
+	}
+	assert.Equal(t, []int64{0, 3, 4}, ticks)
+}
+
+func TestNodeSendQueueBlocks(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{CoalesceChanges: true, SendQueueSize: 1, BlockTimeout: 10 * time.Millisecond})
+	defer n.Close()
+	wc := NewMockWriteCloser()
+	release := make(chan struct{})
+	wc.WriteHandler = func(p []byte) (int, error) {
+		<-release
+		return len(p), nil
+	}
+	n.SetOutput(wc)
+	n.SendSignal("demo.Counter/tick", core.Args{0})
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	n.SendSignal("demo.Counter/tick", core.Args{1})
+	// the queue is full, the sender blocks until the timeout drops the message
+	n.SendSignal("demo.Counter/tick", core.Args{2})
+	assert.Equal(t, int64(1), n.Dropped())
+	// a blocked sender continues once the pump made room
+	sent := make(chan struct{})
+	go func() {
+		n.SendSignal("demo.Counter/tick", core.Args{3})
+		close(sent)
+	}()
+	close(release)
+	<-sent
+	assert.Eventually(t, func() bool { return wc.Count() == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), n.Dropped())
+}
+
+func TestNodeFlush(t *testing.T) {
+	r := NewRegistry()
+	n := NewNodeWithOptions(r, NodeOptions{CoalesceChanges: true})
+	defer n.Close()
+	wc := NewMockWriteCloser()
+	release := make(chan struct{})
+	wc.WriteHandler = func(p []byte) (int, error) {
+		<-release
+		return len(p), nil
+	}
+	n.SetOutput(wc)
+	n.SendPropertyChange("demo.Counter/count", 1)
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	n.SendSignal("demo.Counter/reset", core.Args{})
+	n.SendPropertyChange("demo.Counter/count", 2)
+	flushed := make(chan struct{})
+	go func() {
+		n.Flush()
+		close(flushed)
+	}()
+	close(release)
+	<-flushed
+	// all queued messages were written when Flush returned
+	assert.Equal(t, 3, wc.Count())
+}
+
+func TestNodeWriteFrame(t *testing.T) {
+	r := NewRegistry()
+	r.AddObjectSource(NewMockSource("demo.Counter"))
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	data, err := n.conv.ToData(core.MakeLinkMessage("demo.Counter"))
+	assert.Nil(t, err)
+	_, err = n.WriteFrame(core.FrameBinary, data)
+	assert.Nil(t, err)
+	_, err = n.WriteFrame(core.FrameText, data)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return wc.Count() == 2 }, time.Second, time.Millisecond)
+}
+
+func TestNodeMessageFormat(t *testing.T) {
+	r := NewRegistry()
+	r.AddObjectSource(NewMockSource("demo.Counter"))
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.SetMessageFormat(core.FormatMsgPack)
+	assert.Equal(t, core.FormatMsgPack, n.MessageFormat())
+	conv := core.NewConverter(core.FormatMsgPack)
+	data, err := conv.ToData(core.MakeLinkMessage("demo.Counter"))
+	assert.Nil(t, err)
+	_, err = n.WriteFrame(core.FrameBinary, data)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	msg, err := conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgInit, msg.Type())
+}
+
+func TestNodeDrain(t *testing.T) {
+	n, wc, release := blockingNode(t, NodeOptions{InvokeWorkers: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 1, n.Drain(ctx))
+	// invokes received while draining are rejected
+	writeMessage(t, n, core.MakeInvokeMessage(2, "demo.Counter/block", core.Args{}))
+	assert.Eventually(t, func() bool { return wc.Count() == 1 }, time.Second, time.Millisecond)
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgError, msg.Type())
+	close(release)
+	assert.Equal(t, 0, n.Drain(context.Background()))
+	assert.Equal(t, 2, wc.Count())
+	msg, err = n.conv.FromData(wc.Messages[1])
+	assert.Nil(t, err)
+	requestId, _, _ := msg.AsInvokeReply()
+	assert.Equal(t, int64(1), requestId)
+}
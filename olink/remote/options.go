@@ -0,0 +1,107 @@
+package remote
+
+import "time"
+
+// OverflowPolicy decides what a node does when its incoming
+// or its outgoing queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the writer until the message is queued,
+	// the block timeout expired or the node is closed.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued message to make room.
+	OverflowDropOldest
+	// OverflowDisconnect closes the node and its output.
+	OverflowDisconnect
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDisconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// NodeOptions configures how a remote node processes incoming messages.
+type NodeOptions struct {
+	// InvokeWorkers is the number of invokes executed concurrently per node.
+	// Zero or one executes invokes in order on the incoming pump.
+	// Property sets, links and unlinks are always processed in order.
+	InvokeWorkers int
+	// InvokeTimeout is the maximum duration of an invoke.
+	// A timed out invoke is answered with an error message. The source call
+	// keeps its worker until it returns. With in order invokes the pump
+	// continues with other messages, while later invokes are queued (up to
+	// QueueSize, at least one) until the call returned, so they never overlap.
+	// Zero disables the timeout.
+	InvokeTimeout time.Duration
+	// AsyncInvokes is the number of invokes on asynchronous sources which
+	// may run at the same time per node, the pump blocks when it is reached.
+	// Zero uses the default.
+	AsyncInvokes int
+	// QueueSize is the number of incoming messages buffered by the node.
+	QueueSize int
+	// Overflow decides what happens when the incoming queue is full.
+	Overflow OverflowPolicy
+	// BlockTimeout is the maximum duration Write blocks using OverflowBlock.
+	// Zero blocks until the message is queued or the node is closed.
+	BlockTimeout time.Duration
+	// CoalesceChanges queues outgoing messages and writes them from a separate
+	// goroutine. While a property change is queued, a newer change of the same
+	// property replaces the queued value, so a slow subscriber only receives
+	// the latest value. Signals, replies and other messages keep their order.
+	CoalesceChanges bool
+	// SendQueueSize is the maximum number of outgoing messages queued when
+	// changes are coalesced, the Overflow policy applies when it is full.
+	// OverflowBlock blocks the sender. Zero uses the default.
+	SendQueueSize int
+}
+
+// DefaultNodeOptions returns the options used by NewNode
+// if the registry has no other options set.
+func DefaultNodeOptions() NodeOptions {
+	return NodeOptions{
+		InvokeWorkers: 1,
+		AsyncInvokes:  64,
+		QueueSize:     64,
+		Overflow:      OverflowBlock,
+		SendQueueSize: defaultSendQueueSize,
+	}
+}
+
+// defaultSendQueueSize is the number of outgoing messages queued by default
+const defaultSendQueueSize = 1024
+
+// EvictionMode decides when sources created by a source factory are removed.
+type EvictionMode int
+
+const (
+	// EvictNever keeps factory created sources forever.
+	EvictNever EvictionMode = iota
+	// EvictOnUnlink removes the source when the last node unlinks.
+	// Sources which no node links to, e.g. when only used by invokes,
+	// are removed after the IdleTTL or after one minute if it is not set.
+	EvictOnUnlink
+	// EvictAfterIdle removes the source when no node was linked for the idle TTL.
+	// The TTL starts when the source is created, so sources which are only
+	// used by invokes or property sets are evicted as well.
+	EvictAfterIdle
+)
+
+// EvictionPolicy configures the removal of factory created sources.
+// Sources added using AddObjectSource are never evicted.
+type EvictionPolicy struct {
+	Mode EvictionMode
+	// IdleTTL is the idle duration used by EvictAfterIdle
+	// and for sources which were never linked by EvictOnUnlink.
+	IdleTTL time.Duration
+}
+
+// defaultUnlinkedTTL is the idle TTL of never linked sources for EvictOnUnlink
+const defaultUnlinkedTTL = time.Minute
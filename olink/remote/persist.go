@@ -0,0 +1,152 @@
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/log"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// PersistOptions selects the persisted objects and when they are saved.
+type PersistOptions struct {
+	// Pattern selects the persisted objects, nil persists all objects.
+	Pattern ObjectPattern
+	// Interval saves changed objects periodically,
+	// zero saves an object on each property change.
+	Interval time.Duration
+}
+
+// IRestorableSource is an optional interface for sources
+// which restore persisted properties at once.
+// Other sources receive one SetProperty call per property,
+// so read-only properties which reject sets are not restored.
+type IRestorableSource interface {
+	RestoreProperties(props core.KWArgs) error
+}
+
+// persister saves and restores the properties of objects
+type persister struct {
+	mu       sync.Mutex
+	registry *Registry
+	store    PropertyStore
+	opts     PersistOptions
+	dirty    map[string]bool
+	done     chan struct{}
+}
+
+// newPersister creates a persister and starts the save interval
+func newPersister(r *Registry, store PropertyStore, opts PersistOptions) *persister {
+	p := &persister{
+		registry: r,
+		store:    store,
+		opts:     opts,
+		dirty:    make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+	if opts.Interval > 0 {
+		go p.run()
+	}
+	return p
+}
+
+// matches returns true if the object is persisted
+func (p *persister) matches(objectId string) bool {
+	return p.opts.Pattern == nil || p.opts.Pattern.Match(objectId)
+}
+
+// restore loads the stored properties into the source
+func (p *persister) restore(s IObjectSource) {
+	objectId := s.ObjectId()
+	if !p.matches(objectId) {
+		return
+	}
+	props, err := p.store.Load(objectId)
+	if err != nil {
+		log.Warn().Msgf("registry: restore %s failed: %v", objectId, err)
+		return
+	}
+	if len(props) == 0 {
+		return
+	}
+	log.Info().Str("source", objectId).Msg("registry: restore")
+	if rs, ok := s.(IRestorableSource); ok {
+		err = rs.RestoreProperties(props)
+		if err != nil {
+			log.Warn().Msgf("registry: restore %s failed: %v", objectId, err)
+		}
+		return
+	}
+	for name, value := range props {
+		err = s.SetProperty(name, value)
+		if err != nil {
+			log.Warn().Msgf("registry: restore %s/%s failed: %v", objectId, name, err)
+		}
+	}
+}
+
+// changed saves the object or marks it for the next interval
+func (p *persister) changed(objectId string) {
+	if !p.matches(objectId) {
+		return
+	}
+	if p.opts.Interval > 0 {
+		p.mu.Lock()
+		p.dirty[objectId] = true
+		p.mu.Unlock()
+		return
+	}
+	p.save(objectId)
+}
+
+// save stores the current properties of the source
+func (p *persister) save(objectId string) error {
+	s := p.registry.route(objectId).entries.lookupSource(objectId)
+	if s == nil {
+		return nil
+	}
+	props, err := s.CollectProperties()
+	if err == nil {
+		err = p.store.Save(objectId, props)
+	}
+	if err != nil {
+		log.Warn().Msgf("registry: save %s failed: %v", objectId, err)
+	}
+	return err
+}
+
+// flush saves all changed objects and returns the first error
+func (p *persister) flush() error {
+	p.mu.Lock()
+	dirty := p.dirty
+	p.dirty = make(map[string]bool)
+	p.mu.Unlock()
+	var first error
+	for objectId := range dirty {
+		err := p.save(objectId)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// run flushes the changed objects on each interval
+func (p *persister) run() {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.flush()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// stop ends the save interval and flushes the changed objects
+func (p *persister) stop() error {
+	close(p.done)
+	return p.flush()
+}
@@ -0,0 +1,110 @@
+package remote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore is a property store for tests
+type memoryStore struct {
+	objects map[string]core.KWArgs
+	saves   int
+}
+
+func (s *memoryStore) Load(objectId string) (core.KWArgs, error) {
+	return s.objects[objectId], nil
+}
+
+func (s *memoryStore) Save(objectId string, props core.KWArgs) error {
+	s.objects[objectId] = props
+	s.saves++
+	return nil
+}
+
+// propertySource keeps the properties set by clients
+func propertySource(objectId string) *MockSource {
+	s := NewMockSource(objectId)
+	props := core.KWArgs{}
+	s.SetPropertyHandler = func(propertyId string, value core.Any) error {
+		props[propertyId] = value
+		return nil
+	}
+	s.CollectPropertiesHandler = func() (core.KWArgs, error) {
+		return props, nil
+	}
+	return s
+}
+
+func TestRestoreBeforeInit(t *testing.T) {
+	store := &memoryStore{objects: map[string]core.KWArgs{
+		"demo.Counter": {"count": 5},
+		"other.Object": {"count": 7},
+	}}
+	r := NewRegistry()
+	assert.Nil(t, r.SetPropertyStore(store, PersistOptions{Pattern: PrefixPattern("demo.")}))
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return propertySource(objectId)
+	})
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	n.handleMessage(core.MakeLinkMessage("other.Object"))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	_, props := msg.AsInit()
+	assert.Equal(t, float64(5), props["count"])
+	msg, err = n.conv.FromData(wc.Messages[1])
+	assert.Nil(t, err)
+	_, props = msg.AsInit()
+	assert.Empty(t, props)
+}
+
+func TestSaveOnChange(t *testing.T) {
+	store := &memoryStore{objects: map[string]core.KWArgs{}}
+	r := NewRegistry()
+	assert.Nil(t, r.SetPropertyStore(store, PersistOptions{}))
+	r.AddObjectSource(propertySource("demo.Counter"))
+	n := NewNode(r)
+	n.SetOutput(NewMockWriteCloser())
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 2))
+	assert.Equal(t, 1, store.saves)
+	assert.Equal(t, 2, store.objects["demo.Counter"]["count"])
+}
+
+func TestSaveOnInterval(t *testing.T) {
+	store := &memoryStore{objects: map[string]core.KWArgs{}}
+	r := NewRegistry()
+	assert.Nil(t, r.SetPropertyStore(store, PersistOptions{Interval: time.Hour}))
+	r.AddObjectSource(propertySource("demo.Counter"))
+	n := NewNode(r)
+	n.SetOutput(NewMockWriteCloser())
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 1))
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 2))
+	assert.Equal(t, 0, store.saves)
+	assert.Nil(t, r.FlushProperties())
+	assert.Equal(t, 1, store.saves)
+	assert.Equal(t, 2, store.objects["demo.Counter"]["count"])
+	n.handleMessage(core.MakeSetPropertyMessage("demo.Counter/count", 3))
+	assert.Nil(t, r.SetPropertyStore(nil, PersistOptions{}))
+	assert.Equal(t, 3, store.objects["demo.Counter"]["count"])
+}
+
+func TestRestoreReflectSourceReadOnly(t *testing.T) {
+	store := &memoryStore{objects: map[string]core.KWArgs{
+		"demo.Counter": {"count": 5, "name": "restored", "unknown": 1},
+	}}
+	r := NewRegistry()
+	assert.Nil(t, r.SetPropertyStore(store, PersistOptions{}))
+	c := &reflectCounter{Name: "counter"}
+	s, err := NewReflectSource("demo.Counter", c, r)
+	assert.Nil(t, err)
+	assert.Nil(t, r.AddObjectSource(s))
+	// read-only properties are restored as well
+	assert.Equal(t, int64(5), c.Count)
+	assert.Equal(t, "restored", c.Name)
+	assert.Error(t, s.SetProperty("name", "other"))
+}
@@ -0,0 +1,136 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// Policy decides which operations a peer may perform.
+type Policy interface {
+	// Allow returns true if the operation on the object member is allowed.
+	// The member is empty for links.
+	Allow(kind OpKind, objectId string, member string) bool
+}
+
+// PolicyFunc adapts a function to a Policy.
+type PolicyFunc func(kind OpKind, objectId string, member string) bool
+
+// Allow calls the function.
+func (f PolicyFunc) Allow(kind OpKind, objectId string, member string) bool {
+	return f(kind, objectId, member)
+}
+
+// PolicyFactory creates the policy of a peer, the principal is nil for
+// unauthenticated peers. A nil policy denies all operations.
+type PolicyFactory func(principal *Principal) Policy
+
+// AllowAll is a policy allowing all operations.
+var AllowAll Policy = PolicyFunc(func(OpKind, string, string) bool { return true })
+
+// DenyAll is a policy denying all operations.
+var DenyAll Policy = PolicyFunc(func(OpKind, string, string) bool { return false })
+
+// Rule matches operations for a RulePolicy.
+type Rule struct {
+	// Kinds are the operations matched by the rule, empty matches all operations.
+	Kinds []OpKind
+	// Pattern is matched against the object id for links and against the
+	// symbol id "objectId/member" otherwise, using path.Match syntax,
+	// e.g. "demo.*" or "demo.Counter/*". Empty matches everything.
+	Pattern string
+	// Allow is the decision if the rule matches.
+	Allow bool
+}
+
+// matches returns true if the rule matches the operation
+func (r Rule) matches(kind OpKind, objectId string, member string) bool {
+	if len(r.Kinds) > 0 {
+		found := false
+		for _, k := range r.Kinds {
+			if k == kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Pattern == "" {
+		return true
+	}
+	id := objectId
+	if member != "" {
+		id = core.MakeSymbolId(objectId, member)
+	}
+	ok, err := path.Match(r.Pattern, id)
+	return err == nil && ok
+}
+
+// RulePolicy decides using the first matching rule.
+// If no rule matches the default decision is used.
+type RulePolicy struct {
+	Rules   []Rule
+	Default bool
+}
+
+// Allow returns the decision of the first matching rule.
+func (p *RulePolicy) Allow(kind OpKind, objectId string, member string) bool {
+	for _, r := range p.Rules {
+		if r.matches(kind, objectId, member) {
+			return r.Allow
+		}
+	}
+	return p.Default
+}
+
+// AuditEvent records an authorization decision.
+type AuditEvent struct {
+	Time      time.Time
+	NodeId    string
+	Principal *Principal
+	Kind      OpKind
+	ObjectId  string
+	Member    string
+	Allowed   bool
+}
+
+// AuditHandler receives audit events.
+type AuditHandler func(event AuditEvent)
+
+// ErrAccessDenied is returned when the policy denies an operation.
+var ErrAccessDenied = fmt.Errorf("access denied")
+
+// authorize checks the operation against the policy of the node principal.
+// Unlinks are always allowed.
+func (r *Registry) authorize(ctx context.Context, op *Operation, next Handler) (core.Any, error) {
+	if op.Kind == OpUnlink {
+		return next(ctx, op)
+	}
+	r.mu.RLock()
+	factory := r.policyFactory
+	audit := r.audit
+	r.mu.RUnlock()
+	principal := op.Node.Principal()
+	policy := factory(principal)
+	allowed := policy != nil && policy.Allow(op.Kind, op.ObjectId, op.Member)
+	if audit != nil {
+		audit(AuditEvent{
+			Time:      time.Now(),
+			NodeId:    op.Node.Id(),
+			Principal: principal,
+			Kind:      op.Kind,
+			ObjectId:  op.ObjectId,
+			Member:    op.Member,
+			Allowed:   allowed,
+		})
+	}
+	if !allowed {
+		return nil, ErrAccessDenied
+	}
+	return next(ctx, op)
+}
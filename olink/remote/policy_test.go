@@ -0,0 +1,110 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulePolicy(t *testing.T) {
+	p := &RulePolicy{
+		Rules: []Rule{
+			{Kinds: []OpKind{OpInvoke}, Pattern: "demo.Counter/reset", Allow: false},
+			{Pattern: "demo.*", Allow: true},
+			{Pattern: "demo.*/*", Allow: true},
+		},
+	}
+	assert.True(t, p.Allow(OpLink, "demo.Counter", ""))
+	assert.True(t, p.Allow(OpInvoke, "demo.Counter", "increment"))
+	assert.False(t, p.Allow(OpInvoke, "demo.Counter", "reset"))
+	assert.True(t, p.Allow(OpSetProperty, "demo.Counter", "reset"))
+	assert.False(t, p.Allow(OpLink, "admin.Users", ""))
+}
+
+func TestNodeAccessControl(t *testing.T) {
+	r := NewRegistry()
+	r.SetPolicyFactory(func(p *Principal) Policy {
+		if p.HasRole("admin") {
+			return AllowAll
+		}
+		return &RulePolicy{Rules: []Rule{{Kinds: []OpKind{OpLink}, Allow: true}}}
+	})
+	var events []AuditEvent
+	r.OnAudit(func(e AuditEvent) {
+		events = append(events, e)
+	})
+	s := NewMockSource("demo.Counter")
+	invoked := 0
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		invoked++
+		return nil, nil
+	}
+	r.AddObjectSource(s)
+
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	n.handleMessage(core.MakeInvokeMessage(1, "demo.Counter/increment", core.Args{}))
+	assert.Equal(t, 0, invoked)
+	assert.Equal(t, 2, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[1])
+	assert.Nil(t, err)
+	msgType, requestId, errMsg := msg.AsError()
+	assert.Equal(t, core.MsgInvoke, msgType)
+	assert.Equal(t, int64(1), requestId)
+	assert.Contains(t, errMsg, ErrAccessDenied.Error())
+	assert.Equal(t, 2, len(events))
+	assert.True(t, events[0].Allowed)
+	assert.False(t, events[1].Allowed)
+	assert.Equal(t, OpInvoke, events[1].Kind)
+	assert.Equal(t, "increment", events[1].Member)
+
+	n.SetPrincipal(&Principal{Name: "root", Roles: []string{"admin"}})
+	n.handleMessage(core.MakeInvokeMessage(2, "demo.Counter/increment", core.Args{}))
+	assert.Equal(t, 1, invoked)
+	assert.Equal(t, "root", events[2].Principal.Name)
+}
+
+func TestNilPolicyDenies(t *testing.T) {
+	r := NewRegistry()
+	r.SetPolicyFactory(func(p *Principal) Policy { return nil })
+	r.AddObjectSource(NewMockSource("demo.Counter"))
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	assert.Equal(t, 0, len(r.GetRemoteNodes("demo.Counter")))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	assert.Nil(t, err)
+	msgType, _, _ := msg.AsError()
+	assert.Equal(t, core.MsgLink, msgType)
+}
+
+func TestDeniedInvokeSkipsFactory(t *testing.T) {
+	r := NewRegistry()
+	r.SetPolicyFactory(func(p *Principal) Policy {
+		return DenyAll
+	})
+	created := 0
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		created++
+		return NewMockSource(objectId)
+	})
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeInvokeMessage(1, "secret.Obj/x", core.Args{}))
+	n.handleMessage(core.MakeInvokeMessage(2, "missing.Obj/x", core.Args{}))
+	assert.Equal(t, 0, created)
+	assert.False(t, r.IsRegistered("secret.Obj"))
+	// existing and missing objects are denied alike
+	assert.Equal(t, 2, len(wc.Messages))
+	for _, data := range wc.Messages {
+		msg, err := n.conv.FromData(data)
+		assert.Nil(t, err)
+		_, _, errMsg := msg.AsError()
+		assert.Contains(t, errMsg, ErrAccessDenied.Error())
+	}
+}
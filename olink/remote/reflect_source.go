@@ -0,0 +1,406 @@
+package remote
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/apigear-io/objectlink-core-go/log"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// reflectProperty is a struct field exposed as a property
+type reflectProperty struct {
+	index    []int
+	readOnly bool
+}
+
+// ReflectSource exposes a go value as an object source.
+//
+// Exported methods of the value are invokable members named in lower camel case,
+// e.g. the method Increment is invoked as "increment". A method may return
+// nothing, a value, an error or a value and an error. A panic in a method
+// is returned as the error of the invoke.
+// Exported struct fields tagged with `olink:"name"` are properties,
+// the option `olink:"name,readonly"` rejects property sets from clients.
+//
+// Calls into the value are serialized. After a property set or an invoke
+// the properties are compared with the last known values and changes are
+// notified to the linked nodes using the registry.
+// Methods of the value must not call Update or NotifyChanges.
+type ReflectSource struct {
+	mu         sync.Mutex
+	objectId   string
+	registry   *Registry
+	target     reflect.Value
+	methods    map[string]reflect.Value
+	properties map[string]reflectProperty
+	snapshot   core.KWArgs
+	meta       *ObjectMeta
+}
+
+var _ IObjectSource = (*ReflectSource)(nil)
+var _ IMetaSource = (*ReflectSource)(nil)
+var _ IRestorableSource = (*ReflectSource)(nil)
+
+// NewReflectSource creates a source for the target, which must be a pointer to a struct.
+// Property changes are notified using the registry, which may be nil.
+func NewReflectSource(objectId string, target any, registry *Registry) (*ReflectSource, error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("reflect source %s: target must be a pointer to a struct, got %T", objectId, target)
+	}
+	s := &ReflectSource{
+		objectId:   objectId,
+		registry:   registry,
+		target:     v,
+		methods:    make(map[string]reflect.Value),
+		properties: make(map[string]reflectProperty),
+		meta: &ObjectMeta{
+			Properties: make(map[string]PropertyMeta),
+			Strict:     true,
+		},
+	}
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if !m.IsExported() {
+			continue
+		}
+		if n := valueResults(m.Type); n > 1 {
+			return nil, fmt.Errorf("reflect source %s: method %s returns %d values, at most one is supported", objectId, m.Name, n)
+		}
+		s.methods[core.MemberName(m.Name)] = v.Method(i)
+	}
+	for _, f := range reflect.VisibleFields(t.Elem()) {
+		tag, ok := f.Tag.Lookup(core.TagName)
+		if !ok || !f.IsExported() {
+			continue
+		}
+		name, opts := core.ParseTag(tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = core.MemberName(f.Name)
+		}
+		s.properties[name] = reflectProperty{
+			index:    f.Index,
+			readOnly: core.HasTagOption(opts, "readonly"),
+		}
+		s.meta.Properties[name] = PropertyMeta{
+			Type:     propertyType(f.Type),
+			ReadOnly: core.HasTagOption(opts, "readonly"),
+		}
+	}
+	s.snapshot = s.readProperties()
+	return s, nil
+}
+
+// ObjectId returns the object id of the source.
+func (s *ReflectSource) ObjectId() string {
+	return s.objectId
+}
+
+// Target returns the exposed value.
+func (s *ReflectSource) Target() any {
+	return s.target.Interface()
+}
+
+// Invoke calls the exported method of the value.
+func (s *ReflectSource) Invoke(methodId string, args core.Args) (core.Any, error) {
+	name := memberOf(methodId)
+	m, ok := s.methods[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown method %s", s.objectId, name)
+	}
+	in, err := core.ConvertArgs(m.Type(), args)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", s.objectId, name, err)
+	}
+	out, changes, err := s.call(name, m, in)
+	s.notify(changes)
+	if err != nil {
+		return nil, err
+	}
+	return convertResults(out)
+}
+
+// call calls the method while holding the lock and collects the changes.
+// A panic of the method is returned as error.
+func (s *ReflectSource) call(name string, m reflect.Value, in []reflect.Value) (out []reflect.Value, changes core.KWArgs, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf("reflect source %s: %s panicked: %v", s.objectId, name, r)
+			err = fmt.Errorf("%s/%s: panic: %v", s.objectId, name, r)
+		}
+		// the method may have changed properties before it panicked
+		changes = s.collectChanges()
+	}()
+	return m.Call(in), nil, nil
+}
+
+// SetProperty sets the tagged struct field.
+func (s *ReflectSource) SetProperty(propertyId string, value core.Any) error {
+	name := memberOf(propertyId)
+	p, ok := s.properties[name]
+	if !ok {
+		return fmt.Errorf("%s: unknown property %s", s.objectId, name)
+	}
+	if p.readOnly {
+		return fmt.Errorf("%s: property %s is read-only", s.objectId, name)
+	}
+	s.mu.Lock()
+	field := s.target.Elem().FieldByIndex(p.index)
+	v, err := core.ConvertValue(value, field.Type())
+	if err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%s/%s: %w", s.objectId, name, err)
+	}
+	field.Set(v)
+	changes := s.collectChanges()
+	s.mu.Unlock()
+	s.notify(changes)
+	return nil
+}
+
+// RestoreProperties sets the tagged struct fields to persisted values.
+// Unlike SetProperty it also sets read-only properties, unknown properties
+// are ignored. The restored values are not notified as changes.
+func (s *ReflectSource) RestoreProperties(props core.KWArgs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first error
+	for name, value := range props {
+		p, ok := s.properties[name]
+		if !ok {
+			continue
+		}
+		field := s.target.Elem().FieldByIndex(p.index)
+		v, err := core.ConvertValue(value, field.Type())
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("%s/%s: %w", s.objectId, name, err)
+			}
+			continue
+		}
+		field.Set(v)
+	}
+	s.snapshot = s.readProperties()
+	return first
+}
+
+// ObjectMeta describes the tagged fields, unknown properties are rejected.
+func (s *ReflectSource) ObjectMeta() *ObjectMeta {
+	return s.meta
+}
+
+// Linked is called when a node links to the object.
+func (s *ReflectSource) Linked(objectId string, node *Node) error {
+	if objectId != s.objectId {
+		return fmt.Errorf("objectId mismatch %s != %s", s.objectId, objectId)
+	}
+	return nil
+}
+
+// CollectProperties returns the values of all tagged fields.
+func (s *ReflectSource) CollectProperties() (core.KWArgs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readProperties(), nil
+}
+
+// Update calls fn while holding the source lock and notifies property
+// changes made by fn. Use it to modify the value outside of invokes.
+func (s *ReflectSource) Update(fn func()) {
+	s.mu.Lock()
+	if fn != nil {
+		fn()
+	}
+	changes := s.collectChanges()
+	s.mu.Unlock()
+	s.notify(changes)
+}
+
+// NotifyChanges notifies properties which changed since the last notification.
+func (s *ReflectSource) NotifyChanges() {
+	s.Update(nil)
+}
+
+// NotifySignal notifies a signal to the linked nodes.
+func (s *ReflectSource) NotifySignal(name string, args ...any) {
+	if s.registry == nil {
+		return
+	}
+	s.registry.NotifySignal(s.objectId, name, core.Args(args))
+}
+
+// readProperties reads copies of all tagged fields, the caller must hold the lock.
+// The copies do not share memory with the fields, so in place
+// modifications of slices and maps are detected as changes.
+func (s *ReflectSource) readProperties() core.KWArgs {
+	props := make(core.KWArgs, len(s.properties))
+	for name, p := range s.properties {
+		props[name] = cloneValue(s.target.Elem().FieldByIndex(p.index), nil).Interface()
+	}
+	return props
+}
+
+// cloneKey identifies a pointer or map which was already cloned
+type cloneKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// cloneValue returns a deep copy of the value.
+// Unexported struct fields are copied shallow. Pointers and maps which
+// were already cloned are reused from visited, so cycles are kept.
+func cloneValue(v reflect.Value, visited map[cloneKey]reflect.Value) reflect.Value {
+	if visited == nil {
+		visited = make(map[cloneKey]reflect.Value)
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), visited))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), visited))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		key := cloneKey{ptr: v.Pointer(), typ: v.Type()}
+		if c, ok := visited[key]; ok {
+			return c
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		visited[key] = c
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), cloneValue(iter.Value(), visited))
+		}
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := cloneKey{ptr: v.Pointer(), typ: v.Type()}
+		if c, ok := visited[key]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		visited[key] = c
+		c.Elem().Set(cloneValue(v.Elem(), visited))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(cloneValue(v.Elem(), visited))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(cloneValue(v.Field(i), visited))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// collectChanges compares the properties with the snapshot
+// and returns the changed properties, the caller must hold the lock
+func (s *ReflectSource) collectChanges() core.KWArgs {
+	props := s.readProperties()
+	changes := core.KWArgs{}
+	for name, value := range props {
+		if !reflect.DeepEqual(s.snapshot[name], value) {
+			changes[name] = value
+		}
+	}
+	s.snapshot = props
+	return changes
+}
+
+// notify sends the property changes to the registry
+func (s *ReflectSource) notify(changes core.KWArgs) {
+	if len(changes) == 0 || s.registry == nil {
+		return
+	}
+	log.Debug().Msgf("reflect source %s: changes %v", s.objectId, changes)
+	s.registry.NotifyPropertyChange(s.objectId, changes)
+}
+
+// propertyType returns the property meta type of a go type
+func propertyType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat
+	case reflect.String:
+		return TypeString
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	case reflect.Map, reflect.Struct:
+		return TypeObject
+	}
+	return ""
+}
+
+// memberOf returns the member name of a symbol id or the name itself
+func memberOf(id string) string {
+	if strings.Contains(id, "/") {
+		return core.SymbolIdToMember(id)
+	}
+	return id
+}
+
+// valueResults returns the number of results of the method which are not errors
+func valueResults(t reflect.Type) int {
+	n := 0
+	for i := 0; i < t.NumOut(); i++ {
+		if t.Out(i) != errorType {
+			n++
+		}
+	}
+	return n
+}
+
+// convertResults converts the results of a method call to a value and an error
+func convertResults(out []reflect.Value) (core.Any, error) {
+	var result core.Any
+	for _, v := range out {
+		if v.Type() == errorType {
+			if !v.IsNil() {
+				return nil, v.Interface().(error)
+			}
+			continue
+		}
+		result = v.Interface()
+	}
+	return result, nil
+}
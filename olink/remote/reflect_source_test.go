@@ -0,0 +1,186 @@
+package remote
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/require"
+)
+
+type reflectCounter struct {
+	Count   int64  `olink:"count"`
+	Name    string `olink:"name,readonly"`
+	private int64
+}
+
+func (c *reflectCounter) Increment(step int64) int64 {
+	c.Count += step
+	return c.Count
+}
+
+func (c *reflectCounter) Fail() error {
+	return fmt.Errorf("failed")
+}
+
+func TestReflectSourceInvoke(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	c := &reflectCounter{Name: "counter"}
+	s, err := NewReflectSource("demo.Counter", c, r)
+	require.NoError(t, err)
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	r.LinkRemoteNode(s.ObjectId(), n)
+
+	result, err := s.Invoke("increment", core.Args{float64(2)})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result)
+	require.Equal(t, int64(2), c.Count)
+	// the change is notified to the linked node
+	require.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	require.NoError(t, err)
+	propertyId, value := msg.AsPropertyChange()
+	require.Equal(t, "demo.Counter/count", propertyId)
+	require.Equal(t, int64(2), core.AsInt(value))
+
+	_, err = s.Invoke("increment", core.Args{"two"})
+	require.ErrorContains(t, err, "argument 0")
+	_, err = s.Invoke("increment", core.Args{})
+	require.Error(t, err)
+	_, err = s.Invoke("fail", core.Args{})
+	require.ErrorContains(t, err, "failed")
+	_, err = s.Invoke("unknown", core.Args{})
+	require.Error(t, err)
+}
+
+func TestReflectSourceProperties(t *testing.T) {
+	t.Parallel()
+	c := &reflectCounter{Name: "counter"}
+	s, err := NewReflectSource("demo.Counter", c, nil)
+	require.NoError(t, err)
+	props, err := s.CollectProperties()
+	require.NoError(t, err)
+	require.Equal(t, core.KWArgs{"count": int64(0), "name": "counter"}, props)
+	err = s.SetProperty("count", float64(5))
+	require.NoError(t, err)
+	require.Equal(t, int64(5), c.Count)
+	err = s.SetProperty("name", "other")
+	require.ErrorContains(t, err, "read-only")
+	err = s.SetProperty("private", 1)
+	require.Error(t, err)
+	err = s.SetProperty("count", "five")
+	require.Error(t, err)
+}
+
+type reflectList struct {
+	Items []int64          `olink:"items"`
+	Tags  map[string]int64 `olink:"tags"`
+}
+
+func (l *reflectList) Replace(i int64, v int64) {
+	l.Items[i] = v
+}
+
+func (l *reflectList) Tag(name string, v int64) {
+	l.Tags[name] = v
+}
+
+func TestReflectSourceInPlaceChanges(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	l := &reflectList{Items: []int64{1, 2}, Tags: map[string]int64{"a": 1}}
+	s, err := NewReflectSource("demo.List", l, r)
+	require.NoError(t, err)
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	r.LinkRemoteNode(s.ObjectId(), n)
+
+	_, err = s.Invoke("replace", core.Args{float64(0), float64(5)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	require.NoError(t, err)
+	propertyId, value := msg.AsPropertyChange()
+	require.Equal(t, "demo.List/items", propertyId)
+	require.Equal(t, []int64{5, 2}, core.AsArrayInt(value))
+
+	_, err = s.Invoke("tag", core.Args{"a", float64(2)})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(wc.Messages))
+	msg, err = n.conv.FromData(wc.Messages[1])
+	require.NoError(t, err)
+	propertyId, value = msg.AsPropertyChange()
+	require.Equal(t, "demo.List/tags", propertyId)
+	require.Equal(t, float64(2), core.AsProps(value)["a"])
+}
+
+func TestReflectSourceInvalidTarget(t *testing.T) {
+	t.Parallel()
+	_, err := NewReflectSource("demo.Counter", reflectCounter{}, nil)
+	require.Error(t, err)
+}
+
+type reflectPanic struct {
+	Count int64 `olink:"count"`
+}
+
+func (p *reflectPanic) Crash() int64 {
+	p.Count++
+	panic("boom")
+}
+
+func TestReflectSourceInvokePanic(t *testing.T) {
+	t.Parallel()
+	p := &reflectPanic{}
+	s, err := NewReflectSource("demo.Panic", p, nil)
+	require.NoError(t, err)
+	_, err = s.Invoke("crash", core.Args{})
+	require.ErrorContains(t, err, "panic: boom")
+	// the lock is released and the change before the panic is kept
+	props, err := s.CollectProperties()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), props["count"])
+}
+
+type reflectNode struct {
+	Name string
+	Next *reflectNode
+}
+
+type reflectCycle struct {
+	Head *reflectNode   `olink:"head"`
+	Self map[string]any `olink:"self"`
+}
+
+func TestReflectSourceCyclicValues(t *testing.T) {
+	t.Parallel()
+	n := &reflectNode{Name: "a"}
+	n.Next = n
+	c := &reflectCycle{Head: n, Self: map[string]any{}}
+	c.Self["self"] = c.Self
+	s, err := NewReflectSource("demo.Cycle", c, nil)
+	require.NoError(t, err)
+	props, err := s.CollectProperties()
+	require.NoError(t, err)
+	head := props["head"].(*reflectNode)
+	require.NotSame(t, n, head)
+	require.Same(t, head, head.Next)
+}
+
+type reflectPair struct{}
+
+func (p *reflectPair) Pair() (int64, int64, error) {
+	return 1, 2, nil
+}
+
+func TestReflectSourceRejectsMultipleResults(t *testing.T) {
+	t.Parallel()
+	_, err := NewReflectSource("demo.Pair", &reflectPair{}, nil)
+	require.ErrorContains(t, err, "Pair returns 2 values")
+}
@@ -1,6 +1,11 @@
 package remote
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
 	"github.com/apigear-io/objectlink-core-go/helper"
 	"github.com/apigear-io/objectlink-core-go/log"
 
@@ -13,17 +18,32 @@ var nextRegistryId = helper.MakeIdGenerator("r")
 // It is optimized for the retrieval of object sources
 // A object source is registered in the registry and can be retrieved by the object id.
 // The source can have one or more remote nodes linked to it.
+// Child registries can be mounted to serve the objects of a module.
 type Registry struct {
-	id      string
-	entries *remoteEntries
+	mu            sync.RWMutex
+	id            string
+	entries       *remoteEntries
+	nodeOptions   NodeOptions
+	eviction      EvictionPolicy
+	objectMeta    map[string]*ObjectMeta
+	interceptors  []Interceptor
+	policyFactory PolicyFactory
+	audit         AuditHandler
+	mounts        map[string]*Registry
+	persist       *persister
+	journal       *Journal
 }
 
 // NewRegistry creates a new registry.
 func NewRegistry() *Registry {
 	r := &Registry{
-		id:      nextRegistryId(),
-		entries: newRemoteEntries(),
+		id:          nextRegistryId(),
+		entries:     newRemoteEntries(),
+		nodeOptions: DefaultNodeOptions(),
+		objectMeta:  make(map[string]*ObjectMeta),
+		mounts:      make(map[string]*Registry),
 	}
+	r.entries.created = r.restoreSource
 	return r
 }
 
@@ -32,38 +52,320 @@ func (r *Registry) Id() string {
 	return r.id
 }
 
-// SetSourceFactory sets the source factory.
+// SetNodeOptions sets the options used for nodes created by NewNode.
+// Nodes which already exist keep their options.
+func (r *Registry) SetNodeOptions(opts NodeOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodeOptions = opts
+}
+
+// NodeOptions returns the options used for new nodes.
+func (r *Registry) NodeOptions() NodeOptions {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nodeOptions
+}
+
+// SetEvictionPolicy sets when factory created sources are removed.
+func (r *Registry) SetEvictionPolicy(policy EvictionPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.eviction = policy
+}
+
+// EvictionPolicy returns the eviction policy for factory created sources.
+func (r *Registry) EvictionPolicy() EvictionPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.eviction
+}
+
+// SetObjectMeta sets the property metadata of an object,
+// which is enforced by the nodes before a property is set.
+// A nil meta removes the metadata.
+func (r *Registry) SetObjectMeta(objectId string, meta *ObjectMeta) {
+	if c := r.route(objectId); c != r {
+		c.SetObjectMeta(objectId, meta)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if meta == nil {
+		delete(r.objectMeta, objectId)
+		return
+	}
+	r.objectMeta[objectId] = meta
+}
+
+// ObjectMeta returns the property metadata of an object.
+// If no metadata was set, the metadata of the source is used if it implements IMetaSource.
+func (r *Registry) ObjectMeta(objectId string) *ObjectMeta {
+	if c := r.route(objectId); c != r {
+		return c.ObjectMeta(objectId)
+	}
+	r.mu.RLock()
+	meta, ok := r.objectMeta[objectId]
+	r.mu.RUnlock()
+	if ok {
+		return meta
+	}
+	if ms, ok := r.entries.lookupSource(objectId).(IMetaSource); ok {
+		return ms.ObjectMeta()
+	}
+	return nil
+}
+
+// Use appends interceptors to the chain around client operations.
+// Interceptors are called in the order they were added.
+func (r *Registry) Use(interceptors ...Interceptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interceptors = append(r.interceptors, interceptors...)
+}
+
+// SetPolicyFactory enables access control. The factory creates the policy
+// from the principal of the node for every link, property set, invoke and
+// signal. Denied operations are reported to the client as error messages.
+// A nil factory disables access control.
+func (r *Registry) SetPolicyFactory(factory PolicyFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policyFactory = factory
+}
+
+// OnAudit sets the handler receiving the authorization decisions.
+func (r *Registry) OnAudit(handler AuditHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = handler
+}
+
+// intercept runs the operation through the authorization,
+// the interceptor chain and the final handler
+func (r *Registry) intercept(ctx context.Context, op *Operation, final Handler) (core.Any, error) {
+	if r == nil {
+		return final(ctx, op)
+	}
+	r.mu.RLock()
+	interceptors := r.interceptors
+	if r.policyFactory != nil {
+		interceptors = append([]Interceptor{r.authorize}, interceptors...)
+	}
+	r.mu.RUnlock()
+	final = r.interceptChild(op, final)
+	if len(interceptors) == 0 {
+		return final(ctx, op)
+	}
+	return chainInterceptors(interceptors, final)(ctx, op)
+}
+
+// SetPropertyStore enables the persistence of object properties.
+// Properties of the selected objects are saved to the store when they change
+// and restored into a source when it is added or created by a factory,
+// before nodes receive its init message. Property changes must be notified
+// using NotifyPropertyChange or be set by clients to be saved.
+// The store only covers the sources of this registry, sources of mounted
+// child registries are persisted by setting a store on the child.
+// A nil store disables persistence. Changed objects of the previous store are saved.
+func (r *Registry) SetPropertyStore(store PropertyStore, opts PersistOptions) error {
+	r.mu.Lock()
+	old := r.persist
+	r.persist = nil
+	if store != nil {
+		r.persist = newPersister(r, store, opts)
+	}
+	r.mu.Unlock()
+	if old != nil {
+		return old.stop()
+	}
+	return nil
+}
+
+// FlushProperties saves the changed objects which wait for the save interval.
+func (r *Registry) FlushProperties() error {
+	r.mu.RLock()
+	p := r.persist
+	r.mu.RUnlock()
+	if p == nil {
+		return nil
+	}
+	return p.flush()
+}
+
+// restoreSource loads the persisted properties into the source
+func (r *Registry) restoreSource(source IObjectSource) {
+	r.mu.RLock()
+	p := r.persist
+	r.mu.RUnlock()
+	if p != nil {
+		p.restore(source)
+	}
+}
+
+// propertiesChanged saves the properties of the object if it is persisted
+func (r *Registry) propertiesChanged(objectId string) {
+	if c := r.route(objectId); c != r {
+		c.propertiesChanged(objectId)
+		return
+	}
+	r.mu.RLock()
+	p := r.persist
+	r.mu.RUnlock()
+	if p != nil {
+		p.changed(objectId)
+	}
+}
+
+// SetJournal sets the journal recording accepted property sets,
+// property change notifications, invokes and signals. Property sets and invokes
+// are recorded once their outcome is known, failed ones with the error.
+// A nil journal disables recording.
+func (r *Registry) SetJournal(j *Journal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.journal = j
+}
+
+// Journal returns the journal or nil.
+func (r *Registry) Journal() *Journal {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.journal
+}
+
+// record appends the entry to the journal of the registry serving the object
+// or to the own journal
+func (r *Registry) record(e JournalEntry) {
+	j := r.route(e.ObjectId).Journal()
+	if j == nil {
+		j = r.Journal()
+	}
+	if j == nil {
+		return
+	}
+	err := j.Append(e)
+	if err != nil {
+		log.Warn().Msgf("registry: %v", err)
+	}
+}
+
+// SetSourceFactory sets the fallback source factory,
+// which is used if no factory added by AddSourceFactory matches the object id.
 func (r *Registry) SetSourceFactory(factory SourceFactory) {
 	r.entries.setFactory(factory)
 }
 
+// AddSourceFactory adds a source factory for the object ids matching the pattern.
+// The matching factory with the highest priority creates the source,
+// factories with the same priority are tried in the order they were added.
+// If the factory returns nil, the object is not created and no other factory is tried.
+func (r *Registry) AddSourceFactory(pattern ObjectPattern, priority int, factory SourceFactory) {
+	r.entries.addFactory(pattern, priority, factory)
+}
+
 // AddObjectSource adds the object source to the registry.
 func (r *Registry) AddObjectSource(source IObjectSource) error {
+	if source != nil {
+		if c := r.route(source.ObjectId()); c != r {
+			return c.AddObjectSource(source)
+		}
+		if r.entries.lookupSource(source.ObjectId()) == nil {
+			r.restoreSource(source)
+		}
+	}
 	return r.entries.addSource(source)
 }
 
 // RemoveObjectSource removes the object source from the registry.
+// Nodes linked to the object are unlinked and the source is informed,
+// nodes which negotiated core.FeatureRemoteUnlink receive an unlink message,
+// other nodes an error message for the link of the object.
+// An active source becomes inactive and a factory created source is disposed.
 func (r *Registry) RemoveObjectSource(source IObjectSource) {
 	if source == nil {
 		log.Warn().Msg("registry: source is nil")
 		return
 	}
-	r.entries.removeEntry(source.ObjectId())
+	if c := r.route(source.ObjectId()); c != r {
+		c.RemoveObjectSource(source)
+		return
+	}
+	objectId := source.ObjectId()
+	e := r.entries.removeEntry(objectId)
+	if e == nil {
+		return
+	}
+	old := e.getSource()
+	for _, n := range e.getNodes() {
+		sourceUnlinked(objectId, old, n)
+		n.sendUnlink(objectId)
+	}
+	e.deactivate(objectId)
+	e.RLock()
+	fromFactory := e.fromFactory
+	e.RUnlock()
+	if d, ok := old.(IDisposableSource); ok && fromFactory {
+		d.Dispose()
+	}
+}
+
+// ReplaceObjectSource replaces the source of the object or adds it.
+// Nodes linked to the object stay linked to the new source
+// and receive an init message with its properties.
+// A replaced factory created source is disposed.
+func (r *Registry) ReplaceObjectSource(source IObjectSource) error {
+	if source == nil {
+		return fmt.Errorf("source is nil")
+	}
+	objectId := source.ObjectId()
+	if c := r.route(objectId); c != r {
+		return c.ReplaceObjectSource(source)
+	}
+	r.restoreSource(source)
+	old, fromFactory, nodes := r.entries.replaceSource(source)
+	if old != source {
+		if d, ok := old.(IDisposableSource); ok && fromFactory {
+			d.Dispose()
+		}
+	}
+	for _, n := range nodes {
+		err := source.Linked(objectId, n)
+		if err != nil {
+			log.Warn().Msgf("registry: source %s linked error: %v", objectId, err)
+		}
+		err = n.sendInit(objectId, source)
+		if err != nil {
+			log.Warn().Msgf("registry: init %s failed: %v", objectId, err)
+		}
+	}
+	return nil
 }
 
 // GetObjectSource returns the object source by name.
 func (r *Registry) GetObjectSource(objectId string) IObjectSource {
-	return r.entries.getSource(objectId)
+	c := r.route(objectId)
+	s, created := c.entries.getSource(objectId)
+	if created {
+		// evict the source if no node links to it, e.g. when only used by invokes
+		c.scheduleIdleEviction(objectId)
+	}
+	return s
+}
+
+// lookupSource returns the source of the object without using a source factory
+func (r *Registry) lookupSource(objectId string) IObjectSource {
+	return r.route(objectId).entries.lookupSource(objectId)
 }
 
 // Checks if the object is registered.
 func (r *Registry) IsRegistered(objectId string) bool {
-	return r.entries.hasEntry(objectId)
+	return r.route(objectId).entries.hasEntry(objectId)
 }
 
 // GetRemoteNode returns the node that is linked to the object.
 func (r *Registry) GetRemoteNodes(objectId string) []*Node {
-	return r.entries.getNodes(objectId)
+	return r.route(objectId).entries.getNodes(objectId)
 }
 
 // AttachRemoteNode attaches the node to the registry.
@@ -72,37 +374,169 @@ func (r *Registry) AttachRemoteNode(node *Node) {
 
 // DetachRemoteNode removes the link between the object and the node.
 func (r *Registry) DetachRemoteNode(node *Node) {
-	r.entries.purgeNode(node)
+	results := r.entries.purgeNode(node)
+	for _, res := range results {
+		r.notifyUnlinked(res.objectId, node, res.last)
+	}
+	for _, c := range r.children() {
+		c.DetachRemoteNode(node)
+	}
 }
 
 // LinkRemoteNode adds a link between the object and the node.
 func (r *Registry) LinkRemoteNode(objectId string, node *Node) {
-	r.entries.addNode(objectId, node)
+	if c := r.route(objectId); c != r {
+		c.LinkRemoteNode(objectId, node)
+		return
+	}
+	r.linkRemoteNode(objectId, node, nil)
+}
+
+// linkRemoteNode links the node to the object if the object still has the source.
+// returns false if the source was removed since it was looked up.
+func (r *Registry) linkRemoteNode(objectId string, node *Node, source IObjectSource) bool {
+	if c := r.route(objectId); c != r {
+		return c.linkRemoteNode(objectId, node, source)
+	}
+	first, err := r.entries.linkNode(objectId, node, source)
+	if errors.Is(err, errSourceChanged) {
+		return false
+	}
+	if err == nil && first {
+		r.entries.updateActive(objectId)
+	}
+	return true
 }
 
 // UnlinkRemoteNode removes the link between the object and the node.
 func (r *Registry) UnlinkRemoteNode(objectId string, node *Node) {
-	r.entries.removeNode(objectId, node)
+	if c := r.route(objectId); c != r {
+		c.UnlinkRemoteNode(objectId, node)
+		return
+	}
+	removed, last := r.entries.removeNode(objectId, node)
+	if !removed {
+		return
+	}
+	r.notifyUnlinked(objectId, node, last)
+}
+
+// notifyUnlinked informs the source that the node has left.
+// If it was the last node, the source becomes inactive.
+func (r *Registry) notifyUnlinked(objectId string, node *Node, last bool) {
+	s := r.entries.lookupSource(objectId)
+	if s == nil {
+		return
+	}
+	sourceUnlinked(objectId, s, node)
+	if last {
+		r.entries.updateActive(objectId)
+		r.scheduleEviction(objectId)
+	}
+}
+
+// sourceUnlinked informs the source that the node has left
+func sourceUnlinked(objectId string, s IObjectSource, node *Node) {
+	if us, ok := s.(IUnlinkedSource); ok {
+		err := us.Unlinked(objectId, node)
+		if err != nil {
+			log.Warn().Msgf("registry: source %s unlinked error: %v", objectId, err)
+		}
+	}
+}
+
+// scheduleEviction removes a factory created source according to the eviction policy.
+func (r *Registry) scheduleEviction(objectId string) {
+	policy := r.EvictionPolicy()
+	switch policy.Mode {
+	case EvictOnUnlink:
+		r.evictSource(objectId)
+	case EvictAfterIdle:
+		r.entries.startIdleTimer(objectId, policy.IdleTTL, func() {
+			r.evictSource(objectId)
+		})
+	}
+}
+
+// scheduleIdleEviction removes a factory created source which no node links to.
+// EvictOnUnlink waits for the idle TTL as well, as there is no unlink.
+func (r *Registry) scheduleIdleEviction(objectId string) {
+	policy := r.EvictionPolicy()
+	if policy.Mode == EvictNever {
+		return
+	}
+	ttl := policy.IdleTTL
+	if policy.Mode == EvictOnUnlink && ttl <= 0 {
+		ttl = defaultUnlinkedTTL
+	}
+	r.entries.startIdleTimer(objectId, ttl, func() {
+		r.evictSource(objectId)
+	})
+}
+
+// evictSource removes an idle factory created source and disposes it.
+func (r *Registry) evictSource(objectId string) {
+	s := r.entries.evict(objectId)
+	if s == nil {
+		return
+	}
+	log.Info().Str("source", objectId).Msg("registry: evict")
+	if d, ok := s.(IDisposableSource); ok {
+		d.Dispose()
+	}
 }
 
 // NotifyPropertyChange notifies the property change to the nodes.
+// Nodes which negotiated batched changes receive all properties in one message,
+// other nodes receive one message per property.
+// Each message is encoded once per message format and the same data
+// is written to all node outputs, outputs must not modify written data.
 func (r *Registry) NotifyPropertyChange(objectId string, kwargs core.KWArgs) {
 	log.Debug().Msgf("registry: notify property change %s", objectId)
-	nodes := r.entries.getNodes(objectId)
+	r.propertiesChanged(objectId)
+	r.record(JournalEntry{Kind: JournalChange, ObjectId: objectId, Value: kwargs})
+	nodes := r.GetRemoteNodes(objectId)
+	if len(nodes) == 0 {
+		return
+	}
+	var single []*Node
+	var batched *encodedMessage
 	for _, n := range nodes {
-		for name, value := range kwargs {
-			propertyId := core.MakeSymbolId(objectId, name)
-			n.SendPropertyChange(propertyId, value)
+		if len(kwargs) > 1 && n.HasFeature(core.FeatureBatchedChanges) {
+			if batched == nil {
+				batched = newEncodedMessage(core.MakePropertyChangesMessage(objectId, kwargs))
+			}
+			n.sendEncoded(batched)
+			continue
+		}
+		single = append(single, n)
+	}
+	if len(single) == 0 {
+		return
+	}
+	for name, value := range kwargs {
+		propertyId := core.MakeSymbolId(objectId, name)
+		msg := newEncodedMessage(core.MakePropertyChangeMessage(propertyId, value))
+		for _, n := range single {
+			n.sendEncoded(msg)
 		}
 	}
 }
 
 // NotifySignal notifies the signal to the nodes that are linked to the object.
+// The signal is encoded once per message format.
 func (r *Registry) NotifySignal(objectId string, name string, args core.Args) {
+	r.notifySignal(objectId, name, args, "")
+}
+
+// notifySignal sends the signal to the linked nodes and records it with the id of the sending node
+func (r *Registry) notifySignal(objectId string, name string, args core.Args, nodeId string) {
 	log.Debug().Msgf("registry: notify signal %s.%s", objectId, name)
+	r.record(JournalEntry{Kind: JournalSignal, NodeId: nodeId, ObjectId: objectId, Member: name, Args: args})
 	signalId := core.MakeSymbolId(objectId, name)
-	nodes := r.entries.getNodes(objectId)
+	nodes := r.GetRemoteNodes(objectId)
+	msg := newEncodedMessage(core.MakeSignalMessage(signalId, args))
 	for _, n := range nodes {
-		n.SendSignal(signalId, args)
+		n.sendEncoded(msg)
 	}
 }
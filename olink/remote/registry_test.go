@@ -1,7 +1,9 @@
 package remote
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/apigear-io/objectlink-core-go/helper"
 	"github.com/apigear-io/objectlink-core-go/olink/core"
@@ -204,3 +206,365 @@ func TestMultiNodePropertyChange(t *testing.T) {
 	require.Equal(t, "demo.Counter/count", propId)
 	require.Equal(t, int64(10), core.AsInt(value))
 }
+
+func TestSourceLifecycle(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	s := NewMockSource("demo.Counter")
+	var active []bool
+	var unlinked []*Node
+	s.ActiveChangedHandler = func(objectId string, a bool) {
+		active = append(active, a)
+	}
+	s.UnlinkedHandler = func(objectId string, node *Node) error {
+		unlinked = append(unlinked, node)
+		return nil
+	}
+	r.AddObjectSource(s)
+	n1 := NewNode(r)
+	n2 := NewNode(r)
+	r.LinkRemoteNode(s.ObjectId(), n1)
+	r.LinkRemoteNode(s.ObjectId(), n2)
+	require.Equal(t, []bool{true}, active)
+	r.UnlinkRemoteNode(s.ObjectId(), n1)
+	require.Equal(t, []*Node{n1}, unlinked)
+	require.Equal(t, []bool{true}, active)
+	r.UnlinkRemoteNode(s.ObjectId(), n1)
+	require.Equal(t, []*Node{n1}, unlinked)
+	r.DetachRemoteNode(n2)
+	require.Equal(t, []*Node{n1, n2}, unlinked)
+	require.Equal(t, []bool{true, false}, active)
+}
+
+func TestActiveChangedConcurrentLinks(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	s := NewMockSource("demo.Counter")
+	var mu sync.Mutex
+	var active []bool
+	s.ActiveChangedHandler = func(objectId string, a bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		active = append(active, a)
+	}
+	r.AddObjectSource(s)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		n := NewNode(r)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.LinkRemoteNode(s.ObjectId(), n)
+				r.UnlinkRemoteNode(s.ObjectId(), n)
+			}
+		}()
+	}
+	wg.Wait()
+	// the calls alternate and end inactive once every node left
+	mu.Lock()
+	require.NotEmpty(t, active)
+	for i, a := range active {
+		require.Equal(t, i%2 == 0, a)
+	}
+	require.False(t, active[len(active)-1])
+	mu.Unlock()
+	n := NewNode(r)
+	r.LinkRemoteNode(s.ObjectId(), n)
+	mu.Lock()
+	require.True(t, active[len(active)-1])
+	mu.Unlock()
+}
+
+type disposableSource struct {
+	*MockSource
+	disposed chan struct{}
+}
+
+func (s *disposableSource) Dispose() {
+	close(s.disposed)
+}
+
+func TestEvictOnUnlink(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetEvictionPolicy(EvictionPolicy{Mode: EvictOnUnlink})
+	s := &disposableSource{MockSource: NewMockSource("demo.Counter"), disposed: make(chan struct{})}
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return s
+	})
+	n := NewNode(r)
+	require.Equal(t, s, r.GetObjectSource("demo.Counter"))
+	r.LinkRemoteNode("demo.Counter", n)
+	r.UnlinkRemoteNode("demo.Counter", n)
+	<-s.disposed
+	require.False(t, r.IsRegistered("demo.Counter"))
+}
+
+func TestEvictAfterIdle(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetEvictionPolicy(EvictionPolicy{Mode: EvictAfterIdle, IdleTTL: 10 * time.Millisecond})
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return NewMockSource(objectId)
+	})
+	n := NewNode(r)
+	r.GetObjectSource("demo.Counter")
+	r.LinkRemoteNode("demo.Counter", n)
+	r.UnlinkRemoteNode("demo.Counter", n)
+	// relinking before the ttl keeps the source
+	r.LinkRemoteNode("demo.Counter", n)
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, r.IsRegistered("demo.Counter"))
+	r.DetachRemoteNode(n)
+	require.Eventually(t, func() bool {
+		return !r.IsRegistered("demo.Counter")
+	}, time.Second, time.Millisecond)
+}
+
+func TestNoEvictionOfAddedSources(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetEvictionPolicy(EvictionPolicy{Mode: EvictOnUnlink})
+	s := NewMockSource("demo.Counter")
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	r.LinkRemoteNode(s.ObjectId(), n)
+	r.UnlinkRemoteNode(s.ObjectId(), n)
+	require.True(t, r.IsRegistered(s.ObjectId()))
+}
+
+func TestNotifyEncodesOnce(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	s := NewMockSource("demo.Counter")
+	r.AddObjectSource(s)
+	wcs := make([]*MockWriteCloser, 3)
+	for i := range wcs {
+		n := NewNode(r)
+		wcs[i] = NewMockWriteCloser()
+		n.SetOutput(wcs[i])
+		r.LinkRemoteNode(s.ObjectId(), n)
+	}
+	r.NotifyPropertyChange(s.ObjectId(), core.KWArgs{"count": 10})
+	r.NotifySignal(s.ObjectId(), "reset", core.Args{})
+	for _, wc := range wcs {
+		require.Equal(t, 2, len(wc.Messages))
+		// all nodes share the same encoded data
+		require.Same(t, &wcs[0].Messages[0][0], &wc.Messages[0][0])
+		require.Same(t, &wcs[0].Messages[1][0], &wc.Messages[1][0])
+	}
+}
+
+func TestBatchedPropertyChange(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	s := NewMockSource("demo.Counter")
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	r.LinkRemoteNode(s.ObjectId(), n)
+	n2 := NewNode(r)
+	wc2 := NewMockWriteCloser()
+	n2.SetOutput(wc2)
+	r.LinkRemoteNode(s.ObjectId(), n2)
+	// only the first node negotiates batched changes
+	n.handleMessage(core.MakeFeaturesMessage([]string{core.FeatureBatchedChanges, "unknown"}))
+	require.True(t, n.HasFeature(core.FeatureBatchedChanges))
+	require.False(t, n.HasFeature("unknown"))
+	require.Equal(t, 1, len(wc.Messages))
+	msg, err := n.conv.FromData(wc.Messages[0])
+	require.Nil(t, err)
+	require.Equal(t, []string{core.FeatureBatchedChanges}, msg.AsFeatures())
+
+	r.NotifyPropertyChange(s.ObjectId(), core.KWArgs{"count": 10, "name": "counter"})
+	require.Equal(t, 2, len(wc.Messages))
+	msg, err = n.conv.FromData(wc.Messages[1])
+	require.Nil(t, err)
+	require.Equal(t, core.MsgPropertyChanges, msg.Type())
+	objectId, props := msg.AsPropertyChanges()
+	require.Equal(t, "demo.Counter", objectId)
+	require.Equal(t, 2, len(props))
+	require.Equal(t, 2, len(wc2.Messages))
+}
+
+func TestRemoveObjectSourceNotifiesNodes(t *testing.T) {
+	r := NewRegistry()
+	s := NewMockSource("demo.Counter")
+	var active []bool
+	s.ActiveChangedHandler = func(objectId string, a bool) {
+		active = append(active, a)
+	}
+	unlinked := 0
+	s.UnlinkedHandler = func(objectId string, node *Node) error {
+		unlinked++
+		return nil
+	}
+	r.AddObjectSource(s)
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeFeaturesMessage([]string{core.FeatureRemoteUnlink}))
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	// a node without remote unlinks receives an error for the link
+	legacy := NewNode(r)
+	legacyWc := NewMockWriteCloser()
+	legacy.SetOutput(legacyWc)
+	legacy.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	r.RemoveObjectSource(s)
+	require.False(t, r.IsRegistered("demo.Counter"))
+	require.Equal(t, []bool{true, false}, active)
+	require.Equal(t, 2, unlinked)
+	require.Equal(t, 3, wc.Count())
+	msg, err := n.conv.FromData(wc.Messages[2])
+	require.Nil(t, err)
+	require.Equal(t, core.MsgUnlink, msg.Type())
+	require.Equal(t, "demo.Counter", msg.AsUnlink())
+	require.Equal(t, 2, legacyWc.Count())
+	msg, err = legacy.conv.FromData(legacyWc.Messages[1])
+	require.Nil(t, err)
+	require.Equal(t, core.MsgError, msg.Type())
+	msgType, _, reason := msg.AsError()
+	require.Equal(t, core.MsgLink, msgType)
+	require.Equal(t, "demo.Counter: object removed", reason)
+}
+
+func TestRemoveObjectSourceDisposesFactorySource(t *testing.T) {
+	r := NewRegistry()
+	s := &disposableSource{MockSource: NewMockSource("demo.Counter"), disposed: make(chan struct{})}
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return s
+	})
+	require.Equal(t, s, r.GetObjectSource("demo.Counter"))
+	r.RemoveObjectSource(s)
+	require.False(t, r.IsRegistered("demo.Counter"))
+	<-s.disposed
+}
+
+func TestReplaceObjectSource(t *testing.T) {
+	r := NewRegistry()
+	s1 := NewMockSource("demo.Counter")
+	r.AddObjectSource(s1)
+	n := NewNode(r)
+	wc := NewMockWriteCloser()
+	n.SetOutput(wc)
+	n.handleMessage(core.MakeLinkMessage("demo.Counter"))
+	s2 := NewMockSource("demo.Counter")
+	s2.CollectPropertiesHandler = func() (core.KWArgs, error) {
+		return core.KWArgs{"count": 2}, nil
+	}
+	linked := 0
+	s2.LinkedHandler = func(objectId string, node *Node) error {
+		linked++
+		return nil
+	}
+	require.Nil(t, r.ReplaceObjectSource(s2))
+	require.Equal(t, s2, r.GetObjectSource("demo.Counter"))
+	require.Equal(t, 1, linked)
+	require.Equal(t, []*Node{n}, r.GetRemoteNodes("demo.Counter"))
+	require.Equal(t, 2, wc.Count())
+	msg, err := n.conv.FromData(wc.Messages[1])
+	require.Nil(t, err)
+	objectId, props := msg.AsInit()
+	require.Equal(t, "demo.Counter", objectId)
+	require.Equal(t, float64(2), props["count"])
+}
+
+func TestEvictAfterIdleWithoutLink(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetEvictionPolicy(EvictionPolicy{Mode: EvictAfterIdle, IdleTTL: 10 * time.Millisecond})
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return NewMockSource(objectId)
+	})
+	// sources used without a link are evicted as well
+	for _, id := range []string{"demo.A", "demo.B", "demo.C"} {
+		require.NotNil(t, r.GetObjectSource(id))
+	}
+	require.Eventually(t, func() bool {
+		return !r.IsRegistered("demo.A") && !r.IsRegistered("demo.B") && !r.IsRegistered("demo.C")
+	}, time.Second, time.Millisecond)
+}
+
+func TestLinkAfterEviction(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetEvictionPolicy(EvictionPolicy{Mode: EvictOnUnlink})
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return &disposableSource{MockSource: NewMockSource(objectId), disposed: make(chan struct{})}
+	})
+	n := NewNode(r)
+	s := r.GetObjectSource("demo.Counter")
+	r.LinkRemoteNode("demo.Counter", n)
+	r.UnlinkRemoteNode("demo.Counter", n)
+	// the looked up source was evicted, so linking to it fails
+	require.False(t, r.linkRemoteNode("demo.Counter", n, s))
+	s2 := r.GetObjectSource("demo.Counter")
+	require.NotSame(t, s, s2)
+	require.True(t, r.linkRemoteNode("demo.Counter", n, s2))
+	require.Equal(t, []*Node{n}, r.GetRemoteNodes("demo.Counter"))
+}
+
+func TestRefusedSourceIsNotRegistered(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return nil
+	})
+	require.Nil(t, r.GetObjectSource("demo.Unknown"))
+	require.False(t, r.IsRegistered("demo.Unknown"))
+	n := NewNode(r)
+	r.LinkRemoteNode("demo.Unknown", n)
+	r.UnlinkRemoteNode("demo.Unknown", n)
+	require.False(t, r.IsRegistered("demo.Unknown"))
+}
+
+func TestConcurrentFactoryCreateDisposesLoser(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var calls sync.WaitGroup
+	calls.Add(2)
+	created := make(chan *disposableSource, 2)
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		s := &disposableSource{MockSource: NewMockSource(objectId), disposed: make(chan struct{})}
+		created <- s
+		// both calls run the factory before a source is stored
+		calls.Done()
+		calls.Wait()
+		return s
+	})
+	results := make(chan IObjectSource, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- r.GetObjectSource("demo.Counter")
+		}()
+	}
+	s1, s2 := <-results, <-results
+	require.Same(t, s1, s2)
+	disposed := 0
+	for i := 0; i < 2; i++ {
+		s := <-created
+		select {
+		case <-s.disposed:
+			disposed++
+			require.NotSame(t, s1, s)
+		default:
+		}
+	}
+	require.Equal(t, 1, disposed)
+}
+
+func TestEvictOnUnlinkWithoutLink(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.SetEvictionPolicy(EvictionPolicy{Mode: EvictOnUnlink, IdleTTL: 10 * time.Millisecond})
+	s := &disposableSource{MockSource: NewMockSource("demo.Counter"), disposed: make(chan struct{})}
+	r.SetSourceFactory(func(objectId string) IObjectSource {
+		return s
+	})
+	// a source only used by invokes is evicted after the idle TTL
+	require.Equal(t, s, r.GetObjectSource("demo.Counter"))
+	<-s.disposed
+	require.False(t, r.IsRegistered("demo.Counter"))
+}
@@ -0,0 +1,124 @@
+package remote
+
+import "sync"
+
+// queuedData is an encoded message waiting to be written
+type queuedData struct {
+	// key is the property id of a property change, empty otherwise
+	key  string
+	data []byte
+}
+
+// sendQueue holds the outgoing messages of a node.
+// A property change replaces a pending change of the same property
+// in place, unless other messages were queued after the pending change.
+// So a change never overtakes a signal or reply and all other messages
+// keep their order. At most limit items are queued.
+type sendQueue struct {
+	mu        sync.Mutex
+	items     []*queuedData
+	pending   map[string]*queuedData
+	limit     int
+	ready     chan struct{}
+	space     chan struct{}
+	coalesced int64
+	dropped   int64
+}
+
+func newSendQueue(limit int) *sendQueue {
+	return &sendQueue{
+		pending: make(map[string]*queuedData),
+		limit:   max(limit, 1),
+		ready:   make(chan struct{}, 1),
+		space:   make(chan struct{}, 1),
+	}
+}
+
+// push queues the data, a non empty key coalesces with a pending item of the same key
+// returns false if the queue is full and the data was not queued
+func (q *sendQueue) push(key string, data []byte) bool {
+	return q.pushItem(key, data, false)
+}
+
+// pushDropOldest queues the data, dropping the oldest items if the queue is full
+func (q *sendQueue) pushDropOldest(key string, data []byte) {
+	q.pushItem(key, data, true)
+}
+
+func (q *sendQueue) pushItem(key string, data []byte, dropOldest bool) bool {
+	q.mu.Lock()
+	if item, ok := q.pending[key]; ok && key != "" {
+		item.data = data
+		q.coalesced++
+		q.mu.Unlock()
+		return true
+	}
+	for len(q.items) >= q.limit {
+		if !dropOldest {
+			q.mu.Unlock()
+			return false
+		}
+		oldest := q.items[0]
+		q.items = q.items[1:]
+		if q.pending[oldest.key] == oldest {
+			delete(q.pending, oldest.key)
+		}
+		q.dropped++
+	}
+	item := &queuedData{key: key, data: data}
+	q.items = append(q.items, item)
+	if key != "" {
+		q.pending[key] = item
+	} else {
+		// later changes must not move before this message
+		clear(q.pending)
+	}
+	hasSpace := len(q.items) < q.limit
+	q.mu.Unlock()
+	signal(q.ready)
+	if hasSpace {
+		// pass the space on to the next waiting sender
+		signal(q.space)
+	}
+	return true
+}
+
+// popAll removes and returns all queued items
+func (q *sendQueue) popAll() []*queuedData {
+	q.mu.Lock()
+	items := q.items
+	q.items = nil
+	clear(q.pending)
+	q.mu.Unlock()
+	signal(q.space)
+	return items
+}
+
+// drop counts a message which was not queued
+func (q *sendQueue) drop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dropped++
+}
+
+// droppedCount returns the number of dropped messages
+func (q *sendQueue) droppedCount() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// signal notifies a waiting receiver without blocking
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// coalescedCount returns the number of replaced property changes
+func (q *sendQueue) coalescedCount() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.coalesced
+}
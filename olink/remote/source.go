@@ -1,6 +1,10 @@
 package remote
 
-import "github.com/apigear-io/objectlink-core-go/olink/core"
+import (
+	"context"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
 
 type IObjectSource interface {
 	ObjectId() string
@@ -9,3 +13,49 @@ type IObjectSource interface {
 	Linked(objectId string, node *Node) error
 	CollectProperties() (core.KWArgs, error)
 }
+
+// IContextSource is an optional interface for sources which need a context
+// for invoke and set property calls. If a source implements it, the node
+// calls the context variants instead of Invoke and SetProperty.
+// The context is cancelled when the calling node is closed and
+// carries the caller information (see CallerFromContext).
+type IContextSource interface {
+	IObjectSource
+	InvokeContext(ctx context.Context, methodId string, args core.Args) (core.Any, error)
+	SetPropertyContext(ctx context.Context, propertyId string, value core.Any) error
+}
+
+// ReplyFunc delivers the result of an asynchronous invoke.
+// Only the first call is used, later calls are ignored.
+type ReplyFunc func(result core.Any, err error)
+
+// IAsyncSource is an optional interface for sources which reply to invokes
+// asynchronously. InvokeAsync must not block, the result is delivered by
+// calling reply from any goroutine. The context is done when the invoke
+// timed out or the calling node is closed.
+type IAsyncSource interface {
+	IObjectSource
+	InvokeAsync(ctx context.Context, methodId string, args core.Args, reply ReplyFunc)
+}
+
+// IUnlinkedSource is an optional interface for sources which need to know
+// when a node unlinks from the object or is detached from the registry.
+type IUnlinkedSource interface {
+	Unlinked(objectId string, node *Node) error
+}
+
+// IActiveSource is an optional interface for sources which start and stop
+// work on demand. ActiveChanged is called with true when the first node
+// links to the object and with false when the last node leaves.
+// The calls for an object are serialized and always alternate, so the last
+// call matches the link state. ActiveChanged must not link or unlink nodes
+// of the same object.
+type IActiveSource interface {
+	ActiveChanged(objectId string, active bool)
+}
+
+// IDisposableSource is an optional interface for factory created sources
+// which need to release resources when they are evicted from the registry.
+type IDisposableSource interface {
+	Dispose()
+}
@@ -9,6 +9,8 @@ type MockSource struct {
 	SetPropertyHandler       func(propertyId string, value core.Any) error
 	LinkedHandler            func(objectId string, node *Node) error
 	CollectPropertiesHandler func() (core.KWArgs, error)
+	UnlinkedHandler          func(objectId string, node *Node) error
+	ActiveChangedHandler     func(objectId string, active bool)
 }
 
 func NewMockSource(id string) *MockSource {
@@ -48,3 +50,16 @@ func (s *MockSource) Linked(objectId string, node *Node) error {
 	}
 	return nil
 }
+
+func (s *MockSource) Unlinked(objectId string, node *Node) error {
+	if s.UnlinkedHandler != nil {
+		return s.UnlinkedHandler(objectId, node)
+	}
+	return nil
+}
+
+func (s *MockSource) ActiveChanged(objectId string, active bool) {
+	if s.ActiveChangedHandler != nil {
+		s.ActiveChangedHandler(objectId, active)
+	}
+}
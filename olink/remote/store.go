@@ -0,0 +1,123 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"gopkg.in/yaml.v3"
+)
+
+// PropertyStore persists the properties of objects.
+type PropertyStore interface {
+	// Load returns the stored properties of the object or nil.
+	Load(objectId string) (core.KWArgs, error)
+	// Save stores the properties of the object.
+	Save(objectId string, props core.KWArgs) error
+}
+
+// FileStore stores the properties of all objects in one file.
+// Files with the extension .yaml or .yml are written as YAML, other files as JSON.
+// The file is read once when the store is created and rewritten on each save.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	objects map[string]core.KWArgs
+}
+
+var _ PropertyStore = (*FileStore)(nil)
+
+// NewFileStore creates a store using the file, a missing file is created on the first save.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:    path,
+		objects: make(map[string]core.KWArgs),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+	if s.isYaml() {
+		err = yaml.Unmarshal(data, &s.objects)
+	} else {
+		err = json.Unmarshal(data, &s.objects)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file store %s: %w", path, err)
+	}
+	if s.objects == nil {
+		s.objects = make(map[string]core.KWArgs)
+	}
+	return s, nil
+}
+
+// Path returns the path of the file.
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Load returns the stored properties of the object or nil.
+func (s *FileStore) Load(objectId string) (core.KWArgs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	props, ok := s.objects[objectId]
+	if !ok {
+		return nil, nil
+	}
+	result := make(core.KWArgs, len(props))
+	for k, v := range props {
+		result[k] = v
+	}
+	return result, nil
+}
+
+// Save stores the properties of the object and rewrites the file.
+func (s *FileStore) Save(objectId string, props core.KWArgs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := make(core.KWArgs, len(props))
+	for k, v := range props {
+		copied[k] = v
+	}
+	s.objects[objectId] = copied
+	var data []byte
+	var err error
+	if s.isYaml() {
+		data, err = yaml.Marshal(s.objects)
+	} else {
+		data, err = json.MarshalIndent(s.objects, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("file store %s: %w", s.path, err)
+	}
+	// write to a temporary file first, so a crash never leaves a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("file store: %w", err)
+	}
+	return nil
+}
+
+// isYaml returns true if the file has a yaml extension
+func (s *FileStore) isYaml() bool {
+	ext := strings.ToLower(filepath.Ext(s.path))
+	return ext == ".yaml" || ext == ".yml"
+}
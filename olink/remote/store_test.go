@@ -0,0 +1,32 @@
+package remote
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	for _, name := range []string{"store.json", "store.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			s, err := NewFileStore(path)
+			assert.Nil(t, err)
+			props, err := s.Load("demo.Counter")
+			assert.Nil(t, err)
+			assert.Nil(t, props)
+			err = s.Save("demo.Counter", core.KWArgs{"count": 3, "name": "c1"})
+			assert.Nil(t, err)
+			s2, err := NewFileStore(path)
+			assert.Nil(t, err)
+			props, err = s2.Load("demo.Counter")
+			assert.Nil(t, err)
+			assert.Equal(t, "c1", props["name"])
+			v, err := core.ConvertValue(props["count"], int64Type)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(3), v.Int())
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package remote
 
+import "sync"
+
 type MockWriteCloser struct {
+	mu           sync.Mutex
 	Messages     [][]byte
 	WriteHandler func(p []byte) (n int, err error)
 	Closed       bool
@@ -14,7 +17,9 @@ func NewMockWriteCloser() *MockWriteCloser {
 }
 
 func (m *MockWriteCloser) Write(p []byte) (n int, err error) {
+	m.mu.Lock()
 	m.Messages = append(m.Messages, p)
+	m.mu.Unlock()
 	if m.WriteHandler != nil {
 		return m.WriteHandler(p)
 	}
@@ -28,3 +33,10 @@ func (m *MockWriteCloser) Close() error {
 	}
 	return nil
 }
+
+// Count returns the number of written messages.
+func (m *MockWriteCloser) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Messages)
+}
@@ -0,0 +1,295 @@
+package ws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/remote"
+)
+
+// Authenticator authenticates the http request before the websocket upgrade.
+// A returned AuthError selects the http status of the rejection,
+// other errors reject the request with 401 Unauthorized.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*remote.Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(r *http.Request) (*remote.Principal, error)
+
+// Authenticate calls the function.
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*remote.Principal, error) {
+	return f(r)
+}
+
+// AuthError rejects a request with the http status.
+type AuthError struct {
+	// Status is the http error status, other values reject with 401 Unauthorized.
+	Status int
+	// Challenge is sent as WWW-Authenticate header with 401 responses.
+	Challenge string
+	Message   string
+	// missing is set if the request carries no credentials for the authenticator
+	missing bool
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// unauthorized returns a 401 error with the challenge
+func unauthorized(challenge string, format string, args ...any) error {
+	return &AuthError{Status: http.StatusUnauthorized, Challenge: challenge, Message: fmt.Sprintf(format, args...)}
+}
+
+// missingCredentials returns a 401 error for requests without credentials
+func missingCredentials(challenge string, msg string) error {
+	return &AuthError{Status: http.StatusUnauthorized, Challenge: challenge, Message: msg, missing: true}
+}
+
+// Forbidden returns an error rejecting an authenticated request with 403 Forbidden.
+func Forbidden(format string, args ...any) error {
+	return &AuthError{Status: http.StatusForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// rejectRequest writes the http error of a failed authentication
+func rejectRequest(w http.ResponseWriter, err error) {
+	var ae *AuthError
+	if !errors.As(err, &ae) {
+		ae = &AuthError{Status: http.StatusUnauthorized, Message: err.Error()}
+	}
+	status := ae.Status
+	if status < 400 || status > 599 {
+		// net/http panics for invalid status codes
+		status = http.StatusUnauthorized
+	}
+	if status == http.StatusUnauthorized && ae.Challenge != "" {
+		w.Header().Set("WWW-Authenticate", ae.Challenge)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// BearerAuth authenticates the token of an "Authorization: Bearer" header.
+func BearerAuth(validate func(token string) (*remote.Principal, error)) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+		auth := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || token == "" {
+			return nil, missingCredentials("Bearer", "missing bearer token")
+		}
+		p, err := validate(token)
+		if err != nil {
+			return nil, withChallenge(err, `Bearer error="invalid_token"`)
+		}
+		return p, nil
+	})
+}
+
+// BasicAuth authenticates the user and password of an "Authorization: Basic" header.
+func BasicAuth(realm string, validate func(user, password string) (*remote.Principal, error)) Authenticator {
+	challenge := fmt.Sprintf("Basic realm=%q", realm)
+	return AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return nil, missingCredentials(challenge, "missing basic credentials")
+		}
+		p, err := validate(user, password)
+		if err != nil {
+			return nil, withChallenge(err, challenge)
+		}
+		return p, nil
+	})
+}
+
+// withChallenge adds the challenge to errors which are not an AuthError
+func withChallenge(err error, challenge string) error {
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return err
+	}
+	return unauthorized(challenge, "%v", err)
+}
+
+// TokenClaims are the claims of a signed query token.
+type TokenClaims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles,omitempty"`
+	// Expires is the expiry time in unix seconds, zero never expires.
+	Expires int64 `json:"exp,omitempty"`
+}
+
+// SignToken creates a token with the claims signed using HMAC-SHA256.
+// The token has the form base64url(claims) "." base64url(signature).
+func SignToken(secret []byte, claims TokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(secret, p)), nil
+}
+
+// VerifyToken checks the signature and expiry of the token and returns its claims.
+func VerifyToken(secret []byte, token string) (*TokenClaims, error) {
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("malformed token")
+	}
+	s, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(s, tokenSignature(secret, p)) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	var claims TokenClaims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	if claims.Expires != 0 && time.Now().Unix() > claims.Expires {
+		return nil, fmt.Errorf("token expired")
+	}
+	return &claims, nil
+}
+
+// tokenSignature returns the HMAC-SHA256 of the payload
+func tokenSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// QueryTokenAuth authenticates a token created by SignToken in the query parameter,
+// which is useful for browsers which can not set headers on websocket requests.
+func QueryTokenAuth(param string, secret []byte) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+		token := r.URL.Query().Get(param)
+		if token == "" {
+			return nil, missingCredentials("", "missing query token")
+		}
+		claims, err := VerifyToken(secret, token)
+		if err != nil {
+			return nil, unauthorized("", "%v", err)
+		}
+		return &remote.Principal{
+			Name:   claims.Subject,
+			Roles:  claims.Roles,
+			Claims: map[string]any{"exp": claims.Expires},
+		}, nil
+	})
+}
+
+// ClientCertAuth authenticates the verified TLS client certificate.
+// The server must request and verify client certificates, e.g. using
+// tls.Config.ClientAuth = tls.RequireAndVerifyClientCert.
+// If identify is nil, the principal is named after the common name of the certificate.
+func ClientCertAuth(identify func(cert *x509.Certificate) (*remote.Principal, error)) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return nil, missingCredentials("", "missing verified client certificate")
+		}
+		cert := r.TLS.VerifiedChains[0][0]
+		if identify != nil {
+			return identify(cert)
+		}
+		return &remote.Principal{
+			Name: cert.Subject.CommonName,
+			Claims: map[string]any{
+				"organization": cert.Subject.Organization,
+				"dns":          cert.DNSNames,
+				"serial":       cert.SerialNumber.String(),
+			},
+		}, nil
+	})
+}
+
+// AnyAuth tries the authenticators in order and accepts the first success.
+// If all fail, the error of the first authenticator which found credentials
+// in the request is returned, otherwise the error of the first authenticator.
+func AnyAuth(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+		var first, missing error
+		for _, a := range auths {
+			p, err := a.Authenticate(r)
+			if err == nil {
+				return p, nil
+			}
+			var ae *AuthError
+			if errors.As(err, &ae) && ae.missing {
+				if missing == nil {
+					missing = err
+				}
+			} else if first == nil {
+				first = err
+			}
+		}
+		if first != nil {
+			return nil, first
+		}
+		if missing == nil {
+			missing = missingCredentials("", "no authenticator")
+		}
+		return nil, missing
+	})
+}
+
+// StaticTokens returns a validator for BearerAuth accepting the listed tokens.
+func StaticTokens(tokens map[string]*remote.Principal) func(token string) (*remote.Principal, error) {
+	return func(token string) (*remote.Principal, error) {
+		for t, p := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return p, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid token")
+	}
+}
+
+// Credentials add authentication to the handshake request of Dial.
+type Credentials interface {
+	Apply(header http.Header, u *url.URL)
+}
+
+// CredentialsFunc adapts a function to Credentials.
+type CredentialsFunc func(header http.Header, u *url.URL)
+
+// Apply calls the function.
+func (f CredentialsFunc) Apply(header http.Header, u *url.URL) {
+	f(header, u)
+}
+
+// BearerToken sends the token in an "Authorization: Bearer" header.
+func BearerToken(token string) Credentials {
+	return CredentialsFunc(func(header http.Header, u *url.URL) {
+		header.Set("Authorization", "Bearer "+token)
+	})
+}
+
+// BasicCredentials sends the user and password in an "Authorization: Basic" header.
+func BasicCredentials(user, password string) Credentials {
+	return CredentialsFunc(func(header http.Header, u *url.URL) {
+		auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		header.Set("Authorization", "Basic "+auth)
+	})
+}
+
+// QueryToken sends the token in the query parameter of the url.
+func QueryToken(param string, token string) Credentials {
+	return CredentialsFunc(func(header http.Header, u *url.URL) {
+		q := u.Query()
+		q.Set(param, token)
+		u.RawQuery = q.Encode()
+	})
+}
@@ -0,0 +1,270 @@
+package ws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/remote"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// authServer starts a server answering with the name of the authenticated principal
+func authServer(t *testing.T, a Authenticator) *httptest.Server {
+	s := httptest.NewServer(authHandler(a))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func authHandler(a Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			rejectRequest(w, err)
+			return
+		}
+		io.WriteString(w, p.Name)
+	})
+}
+
+// authGet sends a request with the header and returns the response and its body
+func authGet(t *testing.T, client *http.Client, url string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.Nil(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp, string(body)
+}
+
+func TestSignVerifyToken(t *testing.T) {
+	secret := []byte("secret")
+	token, err := SignToken(secret, TokenClaims{Subject: "alice", Roles: []string{"admin"}, Expires: time.Now().Add(time.Minute).Unix()})
+	assert.Nil(t, err)
+	claims, err := VerifyToken(secret, token)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	_, err = VerifyToken([]byte("other"), token)
+	assert.ErrorContains(t, err, "signature")
+	_, err = VerifyToken(secret, strings.Replace(token, ".", "x.", 1))
+	assert.Error(t, err)
+	_, err = VerifyToken(secret, "malformed")
+	assert.ErrorContains(t, err, "malformed")
+	// tokens without expiry never expire
+	token, err = SignToken(secret, TokenClaims{Subject: "bob"})
+	assert.Nil(t, err)
+	_, err = VerifyToken(secret, token)
+	assert.Nil(t, err)
+}
+
+func TestVerifyTokenExpired(t *testing.T) {
+	secret := []byte("secret")
+	token, err := SignToken(secret, TokenClaims{Subject: "alice", Expires: time.Now().Add(-time.Minute).Unix()})
+	assert.Nil(t, err)
+	_, err = VerifyToken(secret, token)
+	assert.ErrorContains(t, err, "expired")
+	s := authServer(t, QueryTokenAuth("token", secret))
+	resp, _ := authGet(t, s.Client(), s.URL+"?token="+token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestBearerAuth(t *testing.T) {
+	s := authServer(t, BearerAuth(func(token string) (*remote.Principal, error) {
+		switch token {
+		case "good":
+			return &remote.Principal{Name: "alice"}, nil
+		case "banned":
+			return nil, Forbidden("banned")
+		}
+		return nil, errors.New("invalid token")
+	}))
+	resp, body := authGet(t, s.Client(), s.URL, http.Header{"Authorization": {"Bearer good"}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "alice", body)
+	resp, _ = authGet(t, s.Client(), s.URL, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+	resp, _ = authGet(t, s.Client(), s.URL, http.Header{"Authorization": {"Bearer bad"}})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
+	resp, _ = authGet(t, s.Client(), s.URL, http.Header{"Authorization": {"Bearer banned"}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("WWW-Authenticate"))
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := authServer(t, BasicAuth("olink", func(user, password string) (*remote.Principal, error) {
+		if user == "alice" && password == "secret" {
+			return &remote.Principal{Name: user}, nil
+		}
+		return nil, errors.New("invalid password")
+	}))
+	req := func(user, password string) *http.Response {
+		r, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		assert.Nil(t, err)
+		r.SetBasicAuth(user, password)
+		resp, err := s.Client().Do(r)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	assert.Equal(t, http.StatusOK, req("alice", "secret").StatusCode)
+	resp := req("alice", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Basic realm="olink"`, resp.Header.Get("WWW-Authenticate"))
+	resp, _ = authGet(t, s.Client(), s.URL, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Basic realm="olink"`, resp.Header.Get("WWW-Authenticate"))
+}
+
+func TestQueryTokenAuth(t *testing.T) {
+	secret := []byte("secret")
+	token, err := SignToken(secret, TokenClaims{Subject: "alice", Roles: []string{"viewer"}})
+	assert.Nil(t, err)
+	s := authServer(t, QueryTokenAuth("token", secret))
+	resp, body := authGet(t, s.Client(), s.URL+"?token="+token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "alice", body)
+	resp, _ = authGet(t, s.Client(), s.URL, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("WWW-Authenticate"))
+}
+
+func TestRejectInvalidStatus(t *testing.T) {
+	for _, status := range []int{0, http.StatusOK, 999} {
+		s := authServer(t, AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+			return nil, &AuthError{Status: status, Challenge: "Bearer", Message: "rejected"}
+		}))
+		resp, _ := authGet(t, s.Client(), s.URL, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "status %d", status)
+		assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestAnyAuth(t *testing.T) {
+	a := AnyAuth(
+		BearerAuth(StaticTokens(map[string]*remote.Principal{"a": {Name: "alice"}})),
+		BearerAuth(StaticTokens(map[string]*remote.Principal{"b": {Name: "bob"}})),
+		BasicAuth("olink", func(user, password string) (*remote.Principal, error) {
+			return &remote.Principal{Name: user}, nil
+		}),
+	)
+	s := authServer(t, a)
+	resp, body := authGet(t, s.Client(), s.URL, http.Header{"Authorization": {"Bearer a"}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "alice", body)
+	// the second authenticator is tried after the first rejected the token
+	resp, body = authGet(t, s.Client(), s.URL, http.Header{"Authorization": {"Bearer b"}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "bob", body)
+	// the error of the first authenticator which found credentials is returned
+	resp, _ = authGet(t, s.Client(), s.URL, http.Header{"Authorization": {"Bearer c"}})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
+	// without credentials the error of the first authenticator is returned
+	resp, _ = authGet(t, s.Client(), s.URL, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+}
+
+// clientCertificate creates a self-signed client certificate with the common name
+func clientCertificate(t *testing.T, name string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"apigear"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+func TestClientCertAuth(t *testing.T) {
+	cert, leaf := clientCertificate(t, "device-1")
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	s := httptest.NewUnstartedServer(authHandler(ClientCertAuth(nil)))
+	s.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: pool}
+	s.StartTLS()
+	defer s.Close()
+	client := s.Client()
+	resp, _ := authGet(t, client, s.URL, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	// a new transport, the connection of the first request is kept alive
+	config := client.Transport.(*http.Transport).TLSClientConfig.Clone()
+	config.Certificates = []tls.Certificate{cert}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	resp, body := authGet(t, client, s.URL, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "device-1", body)
+	// identify maps the certificate to the principal
+	a := ClientCertAuth(func(cert *x509.Certificate) (*remote.Principal, error) {
+		return nil, Forbidden("unknown device %s", cert.Subject.CommonName)
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+	_, err := a.Authenticate(r)
+	assert.ErrorContains(t, err, "unknown device device-1")
+	// plain http requests carry no certificate
+	_, err = a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestDialCredentials(t *testing.T) {
+	secret := []byte("secret")
+	opts := DefaultOptions()
+	principals := make(chan *remote.Principal, 1)
+	opts.Authenticator = AuthenticatorFunc(func(r *http.Request) (*remote.Principal, error) {
+		p, err := QueryTokenAuth("token", secret).Authenticate(r)
+		if err == nil {
+			principals <- p
+		}
+		return p, err
+	})
+	hub := NewHubWithOptions(context.Background(), remote.NewRegistry(), opts)
+	defer hub.Close()
+	s := httptest.NewServer(hub)
+	defer s.Close()
+	url := "ws" + strings.TrimPrefix(s.URL, "http")
+	token, err := SignToken(secret, TokenClaims{Subject: "alice"})
+	assert.Nil(t, err)
+	dialOpts := DefaultOptions()
+	dialOpts.Credentials = QueryToken("token", token)
+	conn, err := DialWithOptions(context.Background(), url, dialOpts)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", (<-principals).Name)
+	conn.Close()
+	dialOpts.Credentials = QueryToken("token", "forged")
+	_, err = DialWithOptions(context.Background(), url, dialOpts)
+	assert.ErrorContains(t, err, "401")
+}
@@ -2,14 +2,19 @@ package ws
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	neturl "net/url"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/apigear-io/objectlink-core-go/log"
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/apigear-io/objectlink-core-go/olink/remote"
 
 	"github.com/gorilla/websocket"
 )
@@ -31,17 +36,6 @@ func (l LogMiddleware) Process(msg []byte) ([]byte, error) {
 	return msg, nil
 }
 
-const (
-
-	// max message size in bytes (1MB)
-	maxMessageSize = 1024 * 1024 // 1MB
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
-	sendWait   = 3 * time.Second
-)
-
 var connId atomic.Int32
 
 func nextConnId() string {
@@ -50,49 +44,136 @@ func nextConnId() string {
 }
 
 func Dial(ctx context.Context, url string) (*Connection, error) {
-	log.Debug().Msgf("dial: %s", url)
-	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	return DialWithOptions(ctx, url, DefaultOptions())
+}
+
+// DialWithOptions connects to the url using the options.
+func DialWithOptions(ctx context.Context, url string, opts Options) (*Connection, error) {
+	opts = opts.withDefaults()
+	// log the url before the credentials are applied, it may carry a query token afterwards
+	target := url
+	log.Debug().Msgf("dial: %s", target)
+	header := opts.Header.Clone()
+	if opts.Credentials != nil {
+		u, err := neturl.Parse(url)
+		if err != nil {
+			return nil, fmt.Errorf("dial: %w", err)
+		}
+		if header == nil {
+			header = http.Header{}
+		}
+		opts.Credentials.Apply(header, u)
+		url = u.String()
+	}
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		TLSClientConfig:  opts.TLSConfig,
+		HandshakeTimeout: opts.HandshakeTimeout,
+		ReadBufferSize:   opts.ReadBufferSize,
+		WriteBufferSize:  opts.WriteBufferSize,
+		Subprotocols:     opts.subprotocols(),
+	}
+	ws, resp, err := dialer.DialContext(ctx, url, header)
 	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial: %s: %w", resp.Status, err)
+		}
 		return nil, fmt.Errorf("dial: %w", err)
 	}
-	log.Debug().Msgf("connected to: %s", url)
-	conn := NewConnection(ctx, ws)
+	format := core.FormatJson
+	if p := ws.Subprotocol(); p != "" {
+		f, ok := opts.selectFormat([]string{p})
+		if !ok {
+			ws.Close()
+			return nil, fmt.Errorf("dial: unsupported subprotocol %s", p)
+		}
+		format = f
+	}
+	log.Debug().Msgf("connected to: %s using %s", target, format)
+	conn := NewConnectionWithOptions(ctx, ws, opts)
+	conn.SetMessageFormat(format)
 	return conn, nil
 }
 
+// ErrConnectionClosed is returned when writing to a closed connection.
+var ErrConnectionClosed = errors.New("connection closed")
+
+// errSendTimeout is returned when a frame could not be queued in time
+var errSendTimeout = errors.New("send timeout")
+
+// frame is an outgoing message with its frame type
+type frame struct {
+	typ  core.FrameType
+	data []byte
+}
+
 type Connection struct {
 	sync.RWMutex
 	id            string
 	socket        *websocket.Conn
-	in            chan []byte
+	in            chan frame
 	ctx           context.Context
 	ctxCancel     context.CancelFunc
 	out           io.WriteCloser
 	closeHandlers []func()
 	middlewares   []Middleware
+	opts          Options
+	format        core.MessageFormat
+	principal     *remote.Principal
+	// closing requests the write pump to send a close frame
+	closing chan []byte
+	// done is closed when both pumps exited
+	done    chan struct{}
+	dropped atomic.Int64
 }
 
 func NewConnection(ctx context.Context, socket *websocket.Conn) *Connection {
+	return NewConnectionWithOptions(ctx, socket, DefaultOptions())
+}
+
+// NewConnectionWithOptions creates a connection using the limits and keepalive timing of the options.
+func NewConnectionWithOptions(ctx context.Context, socket *websocket.Conn, opts Options) *Connection {
+	opts = opts.withDefaults()
 	ctx, cancel := context.WithCancel(ctx)
 	p := &Connection{
 		id:        nextConnId(),
 		socket:    socket,
-		in:        make(chan []byte),
+		in:        make(chan frame),
 		ctx:       ctx,
 		ctxCancel: cancel,
+		opts:      opts,
+		format:    core.FormatJson,
+		closing:   make(chan []byte, 1),
+		done:      make(chan struct{}),
 	}
-	socket.SetReadLimit(maxMessageSize)
+	socket.SetReadLimit(opts.MaxMessageSize)
 	socket.SetPongHandler(func(string) error {
-		deadline := time.Now().Add(pongWait)
+		deadline := time.Now().Add(opts.PongWait)
 		log.Debug().Msgf("conn: handle pong %v", deadline)
 		return socket.SetReadDeadline(deadline)
 	})
 	socket.SetCloseHandler(func(code int, text string) error {
+		log.Debug().Msgf("%s: peer closed: %d %s", p.id, code, text)
+		// reply to the close frame, fails if we sent the first close frame
+		msg := websocket.FormatCloseMessage(code, "")
+		socket.WriteControl(websocket.CloseMessage, msg, time.Now().Add(opts.SendWait))
 		p.Close()
 		return nil
 	})
-	go p.WritePump()
-	go p.ReadPump()
+	var pumps sync.WaitGroup
+	pumps.Add(2)
+	go func() {
+		defer pumps.Done()
+		p.WritePump()
+	}()
+	go func() {
+		defer pumps.Done()
+		p.ReadPump()
+	}()
+	go func() {
+		pumps.Wait()
+		close(p.done)
+	}()
 	return p
 }
 
@@ -122,6 +203,38 @@ func (c *Connection) Close() error {
 	return nil
 }
 
+// Shutdown sends a close frame with the code and reason after the queued
+// messages and waits until the peer replied and both pumps exited.
+// Messages written after the close frame are dropped. If the context is done
+// first, the connection is closed without waiting for the peer.
+func (c *Connection) Shutdown(ctx context.Context, code int, reason string) error {
+	select {
+	case c.closing <- websocket.FormatCloseMessage(code, reason):
+	default:
+		// a close frame is already requested
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.Close()
+		<-c.done
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel which is closed when the connection is closed
+// and both pumps exited.
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+// Dropped returns the number of messages dropped because the connection
+// was closing.
+func (c *Connection) Dropped() int64 {
+	return c.dropped.Load()
+}
+
 func (c *Connection) Id() string {
 	c.RLock()
 	defer c.RUnlock()
@@ -134,6 +247,34 @@ func (c *Connection) Url() string {
 	return c.socket.RemoteAddr().String()
 }
 
+// SetMessageFormat sets the message format, which selects the frame type used by Write.
+func (c *Connection) SetMessageFormat(format core.MessageFormat) {
+	c.Lock()
+	defer c.Unlock()
+	c.format = format
+}
+
+// MessageFormat returns the message format of the connection.
+func (c *Connection) MessageFormat() core.MessageFormat {
+	c.RLock()
+	defer c.RUnlock()
+	return c.format
+}
+
+// SetPrincipal sets the authenticated peer of the connection.
+func (c *Connection) SetPrincipal(p *remote.Principal) {
+	c.Lock()
+	defer c.Unlock()
+	c.principal = p
+}
+
+// Principal returns the authenticated peer of the connection or nil.
+func (c *Connection) Principal() *remote.Principal {
+	c.RLock()
+	defer c.RUnlock()
+	return c.principal
+}
+
 func (c *Connection) SetOutput(out io.WriteCloser) {
 	c.Lock()
 	c.out = out
@@ -141,12 +282,13 @@ func (c *Connection) SetOutput(out io.WriteCloser) {
 }
 
 func (c *Connection) WritePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.opts.PingPeriod)
 	defer func() {
 		c.Close()
 		ticker.Stop()
 		log.Debug().Msgf("%s: exit write pump ", c.id)
 	}()
+	closeSent := false
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -160,11 +302,25 @@ func (c *Connection) WritePump() {
 			c.EmitClosing()
 			return
 		case <-ticker.C:
-			err := c.socket.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(sendWait))
+			err := c.socket.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(c.opts.SendWait))
 			if err != nil {
 				log.Error().Msgf("%s: write ping error: %v", c.id, err)
 			}
-		case bytes := <-c.in:
+		case msg := <-c.closing:
+			log.Info().Msgf("%s: send close frame", c.id)
+			err := c.socket.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.opts.SendWait))
+			if err != nil {
+				log.Error().Msgf("%s: write close error: %v", c.id, err)
+				return
+			}
+			closeSent = true
+		case f := <-c.in:
+			if closeSent {
+				c.dropped.Add(1)
+				log.Warn().Msgf("%s: connection closing, message dropped", c.id)
+				continue
+			}
+			bytes := f.data
 			c.RLock()
 			for _, m := range c.middlewares {
 				data, err := m.Process(bytes)
@@ -176,12 +332,12 @@ func (c *Connection) WritePump() {
 				bytes = data
 			}
 			c.RUnlock()
-			log.Debug().Msgf("%s: write: %s", c.id, string(bytes))
-			err := c.socket.SetWriteDeadline(time.Now().Add(sendWait))
+			log.Debug().Msgf("%s: write %s: %s", c.id, f.typ, string(bytes))
+			err := c.socket.SetWriteDeadline(time.Now().Add(c.opts.SendWait))
 			if err != nil {
 				log.Error().Msgf("%s: set write deadline error: %v", c.id, err)
 			}
-			err = c.socket.WriteMessage(websocket.TextMessage, bytes)
+			err = c.socket.WriteMessage(messageType(f.typ), bytes)
 			if err != nil {
 				log.Error().Msgf("%s: write error: %v", c.id, err)
 			}
@@ -199,12 +355,16 @@ func (c *Connection) ReadPump() {
 		case <-c.ctx.Done():
 			return
 		default:
-			c.socket.SetReadDeadline(time.Now().Add(pongWait))
-			_, bytes, err := c.socket.ReadMessage()
+			c.socket.SetReadDeadline(time.Now().Add(c.opts.PongWait))
+			mt, bytes, err := c.socket.ReadMessage()
 			if err != nil {
 				log.Info().Msgf("%s: can not read: %v", c.id, err)
 				return
 			}
+			typ := core.FrameText
+			if mt == websocket.BinaryMessage {
+				typ = core.FrameBinary
+			}
 			c.RLock()
 			out := c.out
 			c.RUnlock()
@@ -223,7 +383,7 @@ func (c *Connection) ReadPump() {
 				bytes = data
 			}
 			c.RUnlock()
-			_, err = out.Write(bytes)
+			_, err = core.WriteFrame(out, typ, bytes)
 			if err != nil {
 				log.Debug().Msgf("%s: write error: %v", c.id, err)
 			}
@@ -231,7 +391,44 @@ func (c *Connection) ReadPump() {
 	}
 }
 
+// Write sends the data in a frame of the type used by the message format.
 func (c *Connection) Write(bytes []byte) (int, error) {
-	c.in <- bytes
-	return len(bytes), nil
+	return c.WriteFrame(c.MessageFormat().FrameType(), bytes)
+}
+
+// WriteFrame sends the data in a text or binary frame.
+// It returns ErrConnectionClosed when the connection is closed.
+func (c *Connection) WriteFrame(typ core.FrameType, bytes []byte) (int, error) {
+	select {
+	case c.in <- frame{typ: typ, data: bytes}:
+		return len(bytes), nil
+	case <-c.ctx.Done():
+		c.dropped.Add(1)
+		return 0, ErrConnectionClosed
+	}
+}
+
+// writeTimeout queues the frame for the write pump.
+// It fails if the connection is closed or the frame was not queued within the timeout.
+func (c *Connection) writeTimeout(f frame, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.in <- f:
+		return nil
+	case <-c.ctx.Done():
+		c.dropped.Add(1)
+		return ErrConnectionClosed
+	case <-timer.C:
+		c.dropped.Add(1)
+		return errSendTimeout
+	}
+}
+
+// messageType returns the websocket message type of the frame type
+func messageType(typ core.FrameType) int {
+	if typ == core.FrameBinary {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
 }
@@ -2,48 +2,114 @@ package ws
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/apigear-io/objectlink-core-go/log"
 
+	"github.com/apigear-io/objectlink-core-go/olink/core"
 	"github.com/apigear-io/objectlink-core-go/olink/remote"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 // Hub maintains the set of active peers
 // and broadcasts messages to the peers.
 type Hub struct {
 	// node - source registry
 	registry *remote.Registry
+	// mu protects conns, nodes and closing
+	mu sync.Mutex
 	// registered conns
 	conns []*Connection
-	// inbound messages from the peers
-	broadcast chan []byte
+	// remote nodes of the registered conns
+	nodes map[*Connection]*remote.Node
+	// closing is set when the hub shuts down
+	closing bool
 	// register new peers
 	register chan *Connection
 	// unregister peers
 	unregister chan *Connection
 	ctx        context.Context
 	cancel     context.CancelFunc
+	opts       Options
+	upgrader   websocket.Upgrader
+}
+
+// ErrUnknownConnection is returned when no connection has the given id.
+var ErrUnknownConnection = errors.New("unknown connection")
+
+// ConnectionInfo describes an active connection of the hub.
+type ConnectionInfo struct {
+	Id         string
+	RemoteAddr string
+	Format     core.MessageFormat
+	// Principal is the authenticated peer, nil if not authenticated.
+	Principal *remote.Principal
+	// NodeId is the id of the remote node serving the connection.
+	NodeId string
+	// Metadata is the metadata of the remote node.
+	Metadata map[string]string
+}
+
+// ConnectionFilter selects the connections a broadcast is sent to.
+type ConnectionFilter func(info ConnectionInfo) bool
+
+// WithRole selects connections of principals with the role.
+func WithRole(role string) ConnectionFilter {
+	return func(info ConnectionInfo) bool {
+		return info.Principal.HasRole(role)
+	}
 }
 
+// WithFormat selects connections using the message format.
+func WithFormat(format core.MessageFormat) ConnectionFilter {
+	return func(info ConnectionInfo) bool {
+		return info.Format == format
+	}
+}
+
+// ShutdownReport describes what was lost during a hub shutdown.
+type ShutdownReport struct {
+	// Connections is the number of connections open when the shutdown started.
+	Connections int
+	// ForcedClose is the number of connections closed without close handshake.
+	ForcedClose int
+	// AbandonedInvokes is the number of invokes still running when the
+	// shutdown context was done, their replies are lost.
+	AbandonedInvokes int
+	// DroppedMessages is the number of messages dropped by closing connections.
+	DroppedMessages int64
+}
+
+// NewHub creates a hub using the default options, which only accept
+// browser connections from the same host, see Options.AllowedOrigins.
 func NewHub(ctx context.Context, registry *remote.Registry) *Hub {
+	return NewHubWithOptions(ctx, registry, DefaultOptions())
+}
+
+// NewHubWithOptions creates a hub accepting connections using the options.
+func NewHubWithOptions(ctx context.Context, registry *remote.Registry, opts Options) *Hub {
+	opts = opts.withDefaults()
 	ctx, cancel := context.WithCancel(ctx)
 	h := &Hub{
 		registry:   registry,
-		broadcast:  make(chan []byte),
 		register:   make(chan *Connection),
 		unregister: make(chan *Connection),
 		conns:      make([]*Connection, 0),
+		nodes:      make(map[*Connection]*remote.Node),
 		ctx:        ctx,
 		cancel:     cancel,
+		opts:       opts,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: opts.HandshakeTimeout,
+			ReadBufferSize:   opts.ReadBufferSize,
+			WriteBufferSize:  opts.WriteBufferSize,
+			CheckOrigin:      opts.checkOrigin,
+		},
 	}
 	go h.run()
 	return h
@@ -55,32 +121,42 @@ func (h *Hub) run() {
 		case conn := <-h.register:
 			log.Info().Msgf("hub: register: %s", conn.Id())
 			node := remote.NewNode(h.registry)
+			node.SetMessageFormat(conn.MessageFormat())
+			node.SetPrincipal(conn.Principal())
+			node.SetMetadata("connection", conn.Id())
+			node.SetMetadata("remote_addr", conn.Url())
 			conn.SetOutput(node)
 			conn.OnClosing(func() {
-				h.unregister <- conn
+				select {
+				case h.unregister <- conn:
+				case <-h.ctx.Done():
+				}
 				h.registry.DetachRemoteNode(node)
 			})
 			node.SetOutput(conn)
-			h.conns = append(h.conns, conn)
+			h.mu.Lock()
+			closing := h.closing
+			if !closing {
+				h.conns = append(h.conns, conn)
+				h.nodes[conn] = node
+			}
+			h.mu.Unlock()
+			if closing {
+				// registered after the shutdown started
+				go conn.Shutdown(h.ctx, websocket.CloseGoingAway, "server shutdown")
+			}
 		case conn := <-h.unregister:
 			log.Info().Msgf("hub: unregister: %s", conn.Id())
+			h.mu.Lock()
 			for i, c := range h.conns {
 				if c == conn {
 					h.conns = append(h.conns[:i], h.conns[i+1:]...)
+					delete(h.nodes, c)
 					c.Close()
 					break
 				}
 			}
-		case msg := <-h.broadcast:
-			log.Info().Msgf("hub: broadcast: %s", msg)
-			for _, conn := range h.conns {
-				select {
-				case conn.in <- msg:
-				default:
-					close(conn.in)
-					h.unregister <- conn
-				}
-			}
+			h.mu.Unlock()
 		case <-h.ctx.Done():
 			return
 		}
@@ -88,14 +164,209 @@ func (h *Hub) run() {
 }
 
 func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	socket, err := upgrader.Upgrade(w, r, nil)
+	if h.isClosing() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	var principal *remote.Principal
+	if h.opts.Authenticator != nil {
+		p, err := h.opts.Authenticator.Authenticate(r)
+		if err != nil {
+			log.Info().Err(err).Msgf("reject connection from %s", r.RemoteAddr)
+			rejectRequest(w, err)
+			return
+		}
+		principal = p
+	}
+	format, ok := h.opts.selectFormat(websocket.Subprotocols(r))
+	var header http.Header
+	if ok {
+		header = http.Header{"Sec-Websocket-Protocol": {format.Subprotocol()}}
+	}
+	socket, err := h.upgrader.Upgrade(w, r, header)
 	if err != nil {
 		log.Info().Err(err).Msg("error upgrade http call to websocket")
 		return
 	}
-	log.Info().Msgf("new connection: %s", socket.RemoteAddr())
-	conn := NewConnection(h.ctx, socket)
-	h.register <- conn
+	log.Info().Msgf("new connection: %s using %s", socket.RemoteAddr(), format)
+	conn := NewConnectionWithOptions(h.ctx, socket, h.opts)
+	conn.SetMessageFormat(format)
+	conn.SetPrincipal(principal)
+	select {
+	case h.register <- conn:
+	case <-h.ctx.Done():
+		conn.Close()
+	}
+}
+
+// Connections returns the active connections.
+func (h *Hub) Connections() []ConnectionInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	infos := make([]ConnectionInfo, 0, len(h.conns))
+	for _, conn := range h.conns {
+		infos = append(infos, h.connectionInfo(conn))
+	}
+	return infos
+}
+
+// connectionInfo describes the connection, the caller must hold the lock
+func (h *Hub) connectionInfo(conn *Connection) ConnectionInfo {
+	info := ConnectionInfo{
+		Id:         conn.Id(),
+		RemoteAddr: conn.Url(),
+		Format:     conn.MessageFormat(),
+		Principal:  conn.Principal(),
+	}
+	if node := h.nodes[conn]; node != nil {
+		info.NodeId = node.Id()
+		info.Metadata = node.Metadata()
+	}
+	return info
+}
+
+// selectConnections returns the connections matching the filter, nil matches all
+func (h *Hub) selectConnections(filter ConnectionFilter) []*Connection {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var conns []*Connection
+	for _, conn := range h.conns {
+		if filter == nil || filter(h.connectionInfo(conn)) {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// Broadcast sends the data to the connections matching the filter,
+// a nil filter selects all connections. The data is sent unchanged in the
+// frame type of the connection format, use BroadcastMessage to reach
+// connections using different message formats. Connections which do not
+// accept the data within the send wait are closed.
+// It returns the number of connections the data was sent to.
+func (h *Hub) Broadcast(data []byte, filter ConnectionFilter) int {
+	conns := h.selectConnections(filter)
+	frames := make([]frame, len(conns))
+	for i, conn := range conns {
+		frames[i] = frame{typ: conn.MessageFormat().FrameType(), data: data}
+	}
+	return h.sendFrames(conns, frames)
+}
+
+// BroadcastMessage encodes the message in the format of each connection
+// and sends it to the connections matching the filter, a nil filter
+// selects all connections. It returns the number of connections
+// the message was sent to.
+func (h *Hub) BroadcastMessage(msg core.Message, filter ConnectionFilter) (int, error) {
+	conns := h.selectConnections(filter)
+	encoded := make(map[core.MessageFormat][]byte)
+	frames := make([]frame, len(conns))
+	for i, conn := range conns {
+		format := conn.MessageFormat()
+		data, ok := encoded[format]
+		if !ok {
+			var err error
+			conv := core.MessageConverter{Format: format}
+			data, err = conv.ToData(msg)
+			if err != nil {
+				return 0, err
+			}
+			encoded[format] = data
+		}
+		frames[i] = frame{typ: format.FrameType(), data: data}
+	}
+	return h.sendFrames(conns, frames), nil
+}
+
+// sendFrames sends each frame to its connection concurrently
+// and closes connections which are too slow.
+func (h *Hub) sendFrames(conns []*Connection, frames []frame) int {
+	var sent atomic.Int32
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		go func(conn *Connection, f frame) {
+			defer wg.Done()
+			err := conn.writeTimeout(f, h.opts.SendWait)
+			if errors.Is(err, errSendTimeout) {
+				log.Warn().Msgf("hub: broadcast: %s too slow, closing", conn.Id())
+				conn.Close()
+			}
+			if err != nil {
+				return
+			}
+			sent.Add(1)
+		}(conn, frames[i])
+	}
+	wg.Wait()
+	return int(sent.Load())
+}
+
+// Kick sends a close frame with the reason to the connection
+// and waits until it is closed, see Connection.Shutdown.
+func (h *Hub) Kick(ctx context.Context, id string, reason string) error {
+	h.mu.Lock()
+	var conn *Connection
+	for _, c := range h.conns {
+		if c.Id() == id {
+			conn = c
+			break
+		}
+	}
+	h.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownConnection, id)
+	}
+	log.Info().Msgf("hub: kick %s: %s", id, reason)
+	return conn.Shutdown(ctx, websocket.ClosePolicyViolation, reason)
+}
+
+// isClosing returns true if the hub shuts down
+func (h *Hub) isClosing() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closing
+}
+
+// Shutdown gracefully shuts down the hub. It rejects new connections,
+// waits for the running invokes of all connections, flushes their replies,
+// sends close frames and waits until the connections are closed. If the context is done
+// first, the remaining connections are closed forcibly and the context
+// error is returned. The report describes what was lost.
+func (h *Hub) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	h.mu.Lock()
+	h.closing = true
+	conns := append([]*Connection(nil), h.conns...)
+	nodes := make([]*remote.Node, 0, len(conns))
+	for _, conn := range conns {
+		nodes = append(nodes, h.nodes[conn])
+	}
+	h.mu.Unlock()
+	log.Info().Msgf("hub: shutdown %d connections", len(conns))
+	report := ShutdownReport{Connections: len(conns)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		go func(conn *Connection, node *remote.Node) {
+			defer wg.Done()
+			abandoned := node.Drain(ctx)
+			// queued replies must reach the write pump before the close frame
+			node.Flush()
+			err := conn.Shutdown(ctx, websocket.CloseGoingAway, "server shutdown")
+			mu.Lock()
+			defer mu.Unlock()
+			report.AbandonedInvokes += abandoned
+			if err != nil {
+				report.ForcedClose++
+			}
+			report.DroppedMessages += conn.Dropped()
+		}(conn, nodes[i])
+	}
+	wg.Wait()
+	h.cancel()
+	log.Info().Msgf("hub: shutdown done: %+v", report)
+	return report, ctx.Err()
 }
 
 func (h *Hub) Close() {
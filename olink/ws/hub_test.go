@@ -0,0 +1,342 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/apigear-io/objectlink-core-go/olink/remote"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startHub serves a hub for the registry and returns it with its websocket url
+func startHub(t *testing.T, registry *remote.Registry, opts Options) (*Hub, string) {
+	hub := NewHubWithOptions(context.Background(), registry, opts)
+	s := httptest.NewServer(hub)
+	t.Cleanup(func() {
+		s.Close()
+		hub.Close()
+	})
+	return hub, "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// dialRaw connects a plain websocket client to the url
+func dialRaw(t *testing.T, url string, header http.Header) *websocket.Conn {
+	c, _, err := websocket.DefaultDialer.Dial(url, header)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// writeRaw sends the message as json text frame
+func writeRaw(t *testing.T, c *websocket.Conn, msg core.Message) {
+	data, err := json.Marshal(msg)
+	assert.Nil(t, err)
+	assert.Nil(t, c.WriteMessage(websocket.TextMessage, data))
+}
+
+// readRaw reads the next json message
+func readRaw(t *testing.T, c *websocket.Conn) (core.Message, error) {
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var msg core.Message
+	assert.Nil(t, json.Unmarshal(data, &msg))
+	return msg, nil
+}
+
+// waitConnections waits until the hub has n connections
+func waitConnections(t *testing.T, hub *Hub, n int) []ConnectionInfo {
+	assert.Eventually(t, func() bool { return len(hub.Connections()) == n }, time.Second, time.Millisecond)
+	return hub.Connections()
+}
+
+func TestHubShutdown(t *testing.T) {
+	for _, coalesce := range []bool{false, true} {
+		registry := remote.NewRegistry()
+		nodeOpts := remote.DefaultNodeOptions()
+		nodeOpts.CoalesceChanges = coalesce
+		registry.SetNodeOptions(nodeOpts)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s := remote.NewMockSource("demo.Counter")
+		s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+			close(started)
+			<-release
+			return 42, nil
+		}
+		registry.AddObjectSource(s)
+		hub, url := startHub(t, registry, DefaultOptions())
+		c := dialRaw(t, url, nil)
+		waitConnections(t, hub, 1)
+		writeRaw(t, c, core.MakeInvokeMessage(1, "demo.Counter/slow", core.Args{}))
+		<-started
+		done := make(chan ShutdownReport)
+		go func() {
+			report, err := hub.Shutdown(context.Background())
+			assert.Nil(t, err)
+			done <- report
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		// the reply of the running invoke arrives before the close frame
+		msg, err := readRaw(t, c)
+		assert.Nil(t, err)
+		assert.Equal(t, core.MsgInvokeReply, msg.Type())
+		_, err = readRaw(t, c)
+		var ce *websocket.CloseError
+		assert.True(t, errors.As(err, &ce))
+		assert.Equal(t, websocket.CloseGoingAway, ce.Code)
+		assert.Equal(t, "server shutdown", ce.Text)
+		select {
+		case report := <-done:
+			assert.Equal(t, ShutdownReport{Connections: 1}, report)
+		case <-time.After(2 * time.Second):
+			t.Fatal("shutdown did not return")
+		}
+		// new connections are rejected
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.Error(t, err)
+		if resp != nil {
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		}
+	}
+}
+
+func TestHubShutdownTimeout(t *testing.T) {
+	registry := remote.NewRegistry()
+	s := remote.NewMockSource("demo.Counter")
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	s.InvokeHandler = func(methodId string, args core.Args) (core.Any, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}
+	registry.AddObjectSource(s)
+	hub, url := startHub(t, registry, DefaultOptions())
+	c := dialRaw(t, url, nil)
+	waitConnections(t, hub, 1)
+	writeRaw(t, c, core.MakeInvokeMessage(1, "demo.Counter/stuck", core.Args{}))
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := hub.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, report.AbandonedInvokes)
+	assert.Equal(t, 1, report.ForcedClose)
+}
+
+func TestConnectionShutdown(t *testing.T) {
+	type closed struct {
+		messages []string
+		code     int
+		text     string
+	}
+	result := make(chan closed, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		var res closed
+		for {
+			_, data, err := c.ReadMessage()
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				res.code, res.text = ce.Code, ce.Text
+			}
+			if err != nil {
+				break
+			}
+			res.messages = append(res.messages, string(data))
+		}
+		result <- res
+	}))
+	defer s.Close()
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(s.URL, "http"))
+	assert.Nil(t, err)
+	_, err = conn.Write([]byte("one"))
+	assert.Nil(t, err)
+	_, err = conn.Write([]byte("two"))
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// the peer echoes the close frame, so the close handshake completes
+	assert.Nil(t, conn.Shutdown(ctx, websocket.CloseNormalClosure, "bye"))
+	res := <-result
+	assert.Equal(t, []string{"one", "two"}, res.messages)
+	assert.Equal(t, websocket.CloseNormalClosure, res.code)
+	assert.Equal(t, "bye", res.text)
+	// messages written after the shutdown are dropped
+	_, err = conn.Write([]byte("three"))
+	assert.ErrorIs(t, err, ErrConnectionClosed)
+	assert.Equal(t, int64(1), conn.Dropped())
+}
+
+// startAuthHub serves a hub authenticating the bearer tokens "admin" and "user"
+func startAuthHub(t *testing.T, opts Options) (*Hub, string) {
+	opts.Authenticator = BearerAuth(StaticTokens(map[string]*remote.Principal{
+		"admin": {Name: "alice", Roles: []string{"admin"}},
+		"user":  {Name: "bob"},
+	}))
+	return startHub(t, remote.NewRegistry(), opts)
+}
+
+// dialAs connects a plain websocket client with the token and message format
+func dialAs(t *testing.T, url string, token string, format core.MessageFormat) *websocket.Conn {
+	return dialRaw(t, url, http.Header{
+		"Authorization":          {"Bearer " + token},
+		"Sec-Websocket-Protocol": {format.Subprotocol()},
+	})
+}
+
+// connectionOf returns the hub connection with the id
+func connectionOf(hub *Hub, id string) *Connection {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, c := range hub.conns {
+		if c.Id() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestHubConnections(t *testing.T) {
+	hub, url := startAuthHub(t, DefaultOptions())
+	assert.Empty(t, hub.Connections())
+	dialAs(t, url, "admin", core.FormatJson)
+	waitConnections(t, hub, 1)
+	dialAs(t, url, "user", core.FormatMsgPack)
+	infos := waitConnections(t, hub, 2)
+	assert.Equal(t, "alice", infos[0].Principal.Name)
+	assert.Equal(t, core.FormatJson, infos[0].Format)
+	assert.Equal(t, "bob", infos[1].Principal.Name)
+	assert.Equal(t, core.FormatMsgPack, infos[1].Format)
+	for _, info := range infos {
+		assert.NotEmpty(t, info.NodeId)
+		assert.NotEmpty(t, info.RemoteAddr)
+		assert.Equal(t, info.Id, info.Metadata["connection"])
+	}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	hub, url := startAuthHub(t, DefaultOptions())
+	admin := dialAs(t, url, "admin", core.FormatJson)
+	waitConnections(t, hub, 1)
+	user := dialAs(t, url, "user", core.FormatMsgPack)
+	waitConnections(t, hub, 2)
+	assert.Equal(t, 2, hub.Broadcast([]byte("all"), nil))
+	for _, c := range []*websocket.Conn{admin, user} {
+		_, data, err := c.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, "all", string(data))
+	}
+	assert.Equal(t, 1, hub.Broadcast([]byte("admins"), WithRole("admin")))
+	assert.Equal(t, 1, hub.Broadcast([]byte("msgpack"), WithFormat(core.FormatMsgPack)))
+	assert.Equal(t, 0, hub.Broadcast([]byte("none"), WithRole("root")))
+	mt, data, err := admin.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, websocket.TextMessage, mt)
+	assert.Equal(t, "admins", string(data))
+	mt, data, err = user.ReadMessage()
+	assert.Nil(t, err)
+	// the data is sent in the frame type of the connection format
+	assert.Equal(t, websocket.BinaryMessage, mt)
+	assert.Equal(t, "msgpack", string(data))
+}
+
+func TestHubBroadcastMessage(t *testing.T) {
+	hub, url := startAuthHub(t, DefaultOptions())
+	admin := dialAs(t, url, "admin", core.FormatJson)
+	waitConnections(t, hub, 1)
+	user := dialAs(t, url, "user", core.FormatMsgPack)
+	waitConnections(t, hub, 2)
+	msg := core.MakeSignalMessage("demo.Counter/reset", core.Args{"now"})
+	n, err := hub.BroadcastMessage(msg, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	// each connection receives the message in its own format
+	mt, data, err := admin.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, websocket.TextMessage, mt)
+	act, err := core.NewConverter(core.FormatJson).FromData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, core.MsgSignal, act.Type())
+	mt, data, err = user.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, websocket.BinaryMessage, mt)
+	act, err = core.NewConverter(core.FormatMsgPack).FromData(data)
+	assert.Nil(t, err)
+	name, args := act.AsSignal()
+	assert.Equal(t, "demo.Counter/reset", name)
+	assert.Equal(t, core.Args{"now"}, args)
+	n, err = hub.BroadcastMessage(msg, WithRole("admin"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestHubBroadcastSlowConnection(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SendWait = 50 * time.Millisecond
+	hub, url := startAuthHub(t, opts)
+	fast := dialAs(t, url, "admin", core.FormatJson)
+	waitConnections(t, hub, 1)
+	dialAs(t, url, "user", core.FormatJson)
+	infos := waitConnections(t, hub, 2)
+	slow := connectionOf(hub, infos[1].Id)
+	// block the write pump of the slow connection
+	block := make(chan struct{})
+	defer close(block)
+	slow.Use(MiddlewareFunc(func(msg []byte) ([]byte, error) {
+		<-block
+		return msg, nil
+	}))
+	assert.Equal(t, 2, hub.Broadcast([]byte("one"), nil))
+	// the write pump of the slow connection still holds the first message
+	assert.Equal(t, 1, hub.Broadcast([]byte("two"), nil))
+	assert.Error(t, slow.ctx.Err(), "slow connection should be closed")
+	for _, exp := range []string{"one", "two"} {
+		_, data, err := fast.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, exp, string(data))
+	}
+	assert.Equal(t, int64(1), slow.Dropped())
+}
+
+func TestHubKick(t *testing.T) {
+	hub, url := startAuthHub(t, DefaultOptions())
+	c := dialAs(t, url, "user", core.FormatJson)
+	infos := waitConnections(t, hub, 1)
+	err := hub.Kick(context.Background(), "unknown", "bye")
+	assert.ErrorIs(t, err, ErrUnknownConnection)
+	kicked := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		kicked <- hub.Kick(ctx, infos[0].Id, "banned")
+	}()
+	_, _, err = c.ReadMessage()
+	var ce *websocket.CloseError
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, websocket.ClosePolicyViolation, ce.Code)
+	assert.Equal(t, "banned", ce.Text)
+	assert.Nil(t, <-kicked)
+	waitConnections(t, hub, 0)
+}
@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+)
+
+// Options configures hubs, dialers and connections.
+// Zero values are replaced by the defaults.
+type Options struct {
+	// MaxMessageSize is the maximum size of a received message in bytes.
+	MaxMessageSize int64
+	// PongWait is the time allowed to read the next pong message from the peer.
+	PongWait time.Duration
+	// PingPeriod is the period of pings sent to the peer, it must be less than PongWait.
+	PingPeriod time.Duration
+	// SendWait is the time allowed to write a message to the peer.
+	SendWait time.Duration
+	// HandshakeTimeout limits the duration of the websocket handshake.
+	HandshakeTimeout time.Duration
+	// ReadBufferSize and WriteBufferSize are the I/O buffer sizes in bytes.
+	ReadBufferSize  int
+	WriteBufferSize int
+	// AllowedOrigins lists the origins accepted by the hub, either as full
+	// origin (e.g. "https://example.com") or as host (e.g. "example.com:8080").
+	// "*" accepts all origins. If empty, only requests without origin
+	// or from the same host are accepted. Earlier versions accepted all
+	// origins by default, list "*" to keep accepting cross origin browsers.
+	AllowedOrigins []string
+	// Header is sent with the handshake request by Dial.
+	Header http.Header
+	// Formats are the message formats negotiated using the websocket subprotocols
+	// "olink.json", "olink.msgpack" and "olink.cbor". Dial requests them in order
+	// of preference, the hub accepts the first format requested by the client
+	// which is listed. Without a negotiated subprotocol JSON is used.
+	Formats []core.MessageFormat
+	// Authenticator authenticates requests before the hub upgrades them,
+	// nil accepts all requests without principal.
+	Authenticator Authenticator
+	// Credentials are added to the handshake request by Dial.
+	Credentials Credentials
+	// TLSConfig is used by Dial for wss urls, e.g. to present a client certificate.
+	TLSConfig *tls.Config
+}
+
+// DefaultOptions returns the default options.
+func DefaultOptions() Options {
+	return Options{
+		MaxMessageSize:   1024 * 1024, // 1MB
+		PongWait:         60 * time.Second,
+		PingPeriod:       54 * time.Second,
+		SendWait:         3 * time.Second,
+		HandshakeTimeout: 45 * time.Second,
+		ReadBufferSize:   4096,
+		WriteBufferSize:  4096,
+		Formats:          []core.MessageFormat{core.FormatJson, core.FormatMsgPack, core.FormatCbor},
+	}
+}
+
+// withDefaults returns the options with zero values replaced by the defaults
+func (o Options) withDefaults() Options {
+	d := DefaultOptions()
+	if o.MaxMessageSize <= 0 {
+		o.MaxMessageSize = d.MaxMessageSize
+	}
+	if o.PongWait <= 0 {
+		o.PongWait = d.PongWait
+	}
+	if o.PingPeriod <= 0 || o.PingPeriod >= o.PongWait {
+		o.PingPeriod = (o.PongWait * 9) / 10
+	}
+	if o.SendWait <= 0 {
+		o.SendWait = d.SendWait
+	}
+	if o.HandshakeTimeout <= 0 {
+		o.HandshakeTimeout = d.HandshakeTimeout
+	}
+	if o.ReadBufferSize <= 0 {
+		o.ReadBufferSize = d.ReadBufferSize
+	}
+	if o.WriteBufferSize <= 0 {
+		o.WriteBufferSize = d.WriteBufferSize
+	}
+	if len(o.Formats) == 0 {
+		o.Formats = d.Formats
+	}
+	return o
+}
+
+// subprotocols returns the subprotocol names of the supported formats
+func (o Options) subprotocols() []string {
+	var names []string
+	for _, f := range o.Formats {
+		if f.IsSupported() {
+			names = append(names, f.Subprotocol())
+		}
+	}
+	return names
+}
+
+// selectFormat returns the first format requested by the client which is supported.
+// The second result is false if the client requested no known format.
+func (o Options) selectFormat(requested []string) (core.MessageFormat, bool) {
+	for _, name := range requested {
+		f, ok := core.FormatFromSubprotocol(name)
+		if !ok || !f.IsSupported() {
+			continue
+		}
+		for _, allowed := range o.Formats {
+			if f == allowed {
+				return f, true
+			}
+		}
+	}
+	return core.FormatJson, false
+}
+
+// checkOrigin returns true if the origin of the request is allowed
+func (o Options) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if len(o.AllowedOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) || strings.EqualFold(allowed, u.Host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apigear-io/objectlink-core-go/olink/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultOptions(t *testing.T) {
+	o := DefaultOptions()
+	assert.Equal(t, int64(1024*1024), o.MaxMessageSize)
+	assert.Less(t, o.PingPeriod, o.PongWait)
+	assert.Empty(t, o.AllowedOrigins)
+	assert.Equal(t, []core.MessageFormat{core.FormatJson, core.FormatMsgPack, core.FormatCbor}, o.Formats)
+	// the defaults are complete
+	assert.Equal(t, o, o.withDefaults())
+}
+
+func TestOptionsWithDefaults(t *testing.T) {
+	d := DefaultOptions()
+	o := Options{}.withDefaults()
+	assert.Equal(t, d, o)
+	o = Options{
+		MaxMessageSize: 10,
+		PongWait:       10 * time.Second,
+		PingPeriod:     20 * time.Second,
+		SendWait:       -1,
+		Formats:        []core.MessageFormat{core.FormatCbor},
+	}.withDefaults()
+	assert.Equal(t, int64(10), o.MaxMessageSize)
+	assert.Equal(t, 10*time.Second, o.PongWait)
+	// a ping period which is not less than the pong wait is derived from it
+	assert.Equal(t, 9*time.Second, o.PingPeriod)
+	assert.Equal(t, d.SendWait, o.SendWait)
+	assert.Equal(t, d.HandshakeTimeout, o.HandshakeTimeout)
+	assert.Equal(t, []core.MessageFormat{core.FormatCbor}, o.Formats)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com:8080/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+	var cases = []struct {
+		allowed []string
+		origin  string
+		ok      bool
+	}{
+		// by default only requests without origin or from the same host
+		{nil, "", true},
+		{nil, "http://example.com:8080", true},
+		{nil, "http://EXAMPLE.com:8080", true},
+		{nil, "http://example.com", false},
+		{nil, "http://evil.com", false},
+		{nil, "://bad", false},
+		{[]string{"*"}, "http://evil.com", true},
+		{[]string{"https://app.example.com"}, "https://app.example.com", true},
+		{[]string{"https://app.example.com"}, "http://app.example.com", false},
+		{[]string{"app.example.com:3000"}, "http://app.example.com:3000", true},
+		{[]string{"app.example.com:3000"}, "http://app.example.com", false},
+		// listed origins replace the same host rule
+		{[]string{"app.example.com"}, "http://example.com:8080", false},
+	}
+	for _, c := range cases {
+		o := Options{AllowedOrigins: c.allowed}
+		assert.Equal(t, c.ok, o.checkOrigin(request(c.origin)), "allowed %v origin %q", c.allowed, c.origin)
+	}
+}
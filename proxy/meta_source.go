@@ -14,6 +14,7 @@ type MetaSource struct {
 }
 
 var _ remote.IObjectSource = (*MetaSource)(nil)
+var _ remote.IRestorableSource = (*MetaSource)(nil)
 
 func (m *MetaSource) ObjectId() string {
 	return m.id
@@ -38,3 +39,13 @@ func (m *MetaSource) Linked(objectId string, node *remote.Node) error {
 func (m *MetaSource) CollectProperties() (core.KWArgs, error) {
 	return m.Properties, nil
 }
+
+func (m *MetaSource) RestoreProperties(props core.KWArgs) error {
+	if m.Properties == nil {
+		m.Properties = make(core.KWArgs)
+	}
+	for k, v := range props {
+		m.Properties[k] = v
+	}
+	return nil
+}